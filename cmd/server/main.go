@@ -24,6 +24,18 @@ func main() {
 
 	helpers.NewValidate()
 
+	if err := helpers.NewJWTKeySet(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	if err := helpers.NewCookieConfig(); err != nil {
+		log.Fatalf("Failed to load cookie config: %v", err)
+	}
+
+	if err := helpers.NewNIKFormat(); err != nil {
+		log.Fatalf("Failed to load NIK format: %v", err)
+	}
+
 	address := fmt.Sprintf("%s:%s", os.Getenv("APP_HOST"), os.Getenv("APP_PORT"))
 	server := server.NewAPIServer(address, dbpool)
 	if err := server.Launch(); err != nil {
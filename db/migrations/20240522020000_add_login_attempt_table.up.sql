@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+  nip VARCHAR(15) NOT NULL PRIMARY KEY,
+  failed_count INT NOT NULL DEFAULT 0,
+  locked_until TIMESTAMP NULL,
+  last_failed_at TIMESTAMP NULL
+);
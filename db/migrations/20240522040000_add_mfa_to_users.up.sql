@@ -0,0 +1,15 @@
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS mfa_secret TEXT NULL,
+  ADD COLUMN IF NOT EXISTS mfa_enabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS mfa_last_used_step BIGINT NULL;
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id VARCHAR(26) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(26) NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  used_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
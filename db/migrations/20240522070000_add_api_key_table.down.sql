@@ -0,0 +1,8 @@
+ALTER TABLE medical_records
+  DROP COLUMN IF EXISTS created_by_api_key;
+
+ALTER TABLE patients
+  DROP COLUMN IF EXISTS created_by_api_key,
+  DROP COLUMN IF EXISTS created_by;
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id VARCHAR(26) NOT NULL PRIMARY KEY,
+  name VARCHAR(50) NOT NULL,
+  key_prefix VARCHAR(16) NOT NULL,
+  key_hash VARCHAR(64) NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL,
+  created_by VARCHAR(26) NOT NULL,
+  expires_at TIMESTAMP NULL,
+  last_used_at TIMESTAMP NULL,
+  revoked_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+ALTER TABLE patients
+  ADD COLUMN IF NOT EXISTS created_by VARCHAR(26) NULL REFERENCES users(id),
+  ADD COLUMN IF NOT EXISTS created_by_api_key VARCHAR(26) NULL REFERENCES api_keys(id);
+
+ALTER TABLE medical_records
+  ADD COLUMN IF NOT EXISTS created_by_api_key VARCHAR(26) NULL REFERENCES api_keys(id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  state_hash VARCHAR(64) NOT NULL PRIMARY KEY,
+  code_verifier VARCHAR(128) NOT NULL,
+  nonce VARCHAR(128) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
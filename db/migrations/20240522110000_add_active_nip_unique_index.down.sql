@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_active_nip;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS deleted_at;
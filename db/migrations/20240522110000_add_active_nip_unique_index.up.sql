@@ -0,0 +1,6 @@
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+
+UPDATE users SET deleted_at = updated_at WHERE is_deleted = true AND deleted_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_active_nip ON users(nip) WHERE is_deleted = false;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS nip_sequences (
+  prefix SMALLINT NOT NULL,
+  gender SMALLINT NOT NULL,
+  year SMALLINT NOT NULL,
+  month SMALLINT NOT NULL,
+  last_sequence INTEGER NOT NULL,
+  PRIMARY KEY (prefix, gender, year, month)
+);
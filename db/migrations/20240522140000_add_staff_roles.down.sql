@@ -0,0 +1,6 @@
+ALTER TABLE medical_records
+  DROP COLUMN IF EXISTS diagnosis;
+
+-- Postgres cannot drop enum values and users with the staff roles may be
+-- referenced by medical records, so 'doctor', 'pharmacist' and 'registrar'
+-- stay in the roles type.
@@ -0,0 +1,6 @@
+ALTER TYPE roles ADD VALUE IF NOT EXISTS 'doctor';
+ALTER TYPE roles ADD VALUE IF NOT EXISTS 'pharmacist';
+ALTER TYPE roles ADD VALUE IF NOT EXISTS 'registrar';
+
+ALTER TABLE medical_records
+  ADD COLUMN IF NOT EXISTS diagnosis TEXT NULL;
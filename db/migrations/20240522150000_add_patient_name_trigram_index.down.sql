@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_patients_name_trgm;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+)
+
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*auth_entity.ExternalIdentity, error)
+	AllowedRoles() []user_entity.Role
+	TrustsMFA() bool
+}
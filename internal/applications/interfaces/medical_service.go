@@ -4,11 +4,17 @@ import (
 	"context"
 
 	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
 )
 
 type MedicalService interface {
-	CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) error
-	GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, error)
+	CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) ([]string, error)
+	GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, int, error)
+	GetMedicalPatient(ctx context.Context, identityNumber nik.NIK) (*medical_entity.MedicalPatientDetail, error)
+	UpdatePatient(ctx context.Context, payload *medical_entity.UpdateMedicalPatient) (*medical_entity.MedicalPatientDetail, []string, error)
+	PatchPatient(ctx context.Context, payload *medical_entity.PatchMedicalPatient) (*medical_entity.MedicalPatientDetail, []string, error)
+	DeletePatient(ctx context.Context, identityNumber nik.NIK) error
+	RestorePatient(ctx context.Context, identityNumber nik.NIK) error
 	CreateMedicalRecord(ctx context.Context, payload *medical_entity.AddMedicalRecord) error
-	GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, error)
+	GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, int, error)
 }
@@ -3,15 +3,43 @@ package interfaces
 import (
 	"context"
 
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
 	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/helpers"
 )
 
 type UserService interface {
 	CreateITUser(ctx context.Context, payload *user_entity.RegisterITUser) (*user_entity.LoggedInUser, error)
-	CreateNurseUser(ctx context.Context, payload *user_entity.RegisterNurseUser) (*user_entity.LoggedInUser, error)
+	AddITUser(ctx context.Context, payload *user_entity.RegisterITUser) (*user_entity.UserList, error)
+	UpdateITUser(ctx context.Context, payload *user_entity.UpdateITUser) error
+	DisableITUser(ctx context.Context, userId string) error
+	EnableITUser(ctx context.Context, userId string) error
+	DeleteITUser(ctx context.Context, userId string) error
+	CreateStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.RegisterStaffUser) (*user_entity.LoggedInUser, error)
 	LoginUser(ctx context.Context, payload *user_entity.LoginUser) (*user_entity.LoggedInUser, error)
-	GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, error)
-	UpdateNurseUser(ctx context.Context, payload *user_entity.UpdateNurseUser) error
-	DeleteNurseUser(ctx context.Context, userId string) error
-	GiveAccessNurseUser(ctx context.Context, payload *user_entity.GiveAccessNurseUser) error
+	LoginMFA(ctx context.Context, payload *auth_entity.LoginMFA) (*user_entity.LoggedInUser, error)
+	BeginOIDCLogin(ctx context.Context) (*auth_entity.OIDCLogin, error)
+	CompleteOIDCLogin(ctx context.Context, payload *auth_entity.OIDCCallback) (*user_entity.LoggedInUser, error)
+	EnrollMFA(ctx context.Context, userId string) (*auth_entity.MFAEnrollment, error)
+	ActivateMFA(ctx context.Context, payload *auth_entity.MFACode) (*auth_entity.MFARecoveryCodes, error)
+	DisableMFA(ctx context.Context, payload *auth_entity.MFACode) error
+	RefreshToken(ctx context.Context, payload *auth_entity.RefreshTokenPayload) (*user_entity.LoggedInUser, error)
+	Authenticate(ctx context.Context, accessToken string) (*helpers.Credential, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*helpers.Credential, error)
+	CreateAPIKey(ctx context.Context, payload *auth_entity.AddAPIKey) (*auth_entity.CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*auth_entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, apiKeyId string) error
+	LogoutUser(ctx context.Context, credential *helpers.Credential, payload *auth_entity.LogoutPayload) error
+	RevokeUserSessions(ctx context.Context, userId string) error
+	GetSessions(ctx context.Context, userId, currentSessionId string) ([]*auth_entity.Session, error)
+	TerminateSession(ctx context.Context, userId, sessionId string) error
+	UnlockUser(ctx context.Context, userId string) error
+	ChangePassword(ctx context.Context, payload *user_entity.ChangePassword) (*user_entity.LoggedInUser, error)
+	ResetPassword(ctx context.Context, userId string) (*user_entity.ResetPassword, error)
+	GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, int, error)
+	UpdateStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.UpdateStaffUser) error
+	DeleteStaffUser(ctx context.Context, role user_entity.Role, userId string) error
+	RestoreStaffUser(ctx context.Context, role user_entity.Role, userId string) error
+	GiveAccessStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.GiveAccessStaffUser) error
+	RevokeAccessStaffUser(ctx context.Context, role user_entity.Role, userId string) error
 }
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+)
+
+func TestCreateAPIKeyAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+	created, err := s.CreateAPIKey(ctx, &auth_entity.AddAPIKey{
+		Name:      "reporting",
+		Scopes:    []string{string(permission_entity.PatientsRead)},
+		CreatedBy: user.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, auth_entity.APIKeyPrefix) || created.Prefix != created.Key[:auth_entity.APIKeyDisplayLength] {
+		t.Fatalf("unexpected key %q with prefix %q", created.Key, created.Prefix)
+	}
+	if stored := authRepository.apiKeys[created.ID]; strings.Contains(stored.KeyHash, created.Key) {
+		t.Fatal("key stored in clear")
+	}
+
+	credential, err := s.AuthenticateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if credential.UserId != user.ID || credential.APIKeyID != created.ID || len(credential.Scopes) != 1 {
+		t.Fatalf("unexpected credential %+v", credential)
+	}
+	if authRepository.apiKeys[created.ID].LastUsedAt == nil {
+		t.Fatal("usage not recorded")
+	}
+}
+
+func TestAuthenticateAPIKeyRejects(t *testing.T) {
+	ctx := context.Background()
+	expired := time.Now().UTC().Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		key  func(t *testing.T, s *UserService, authRepository *fakeAuthRepository) string
+	}{
+		{
+			name: "missing prefix",
+			key: func(t *testing.T, s *UserService, authRepository *fakeAuthRepository) string {
+				return strings.TrimPrefix(newTestAPIKey(t, s).Key, auth_entity.APIKeyPrefix)
+			},
+		},
+		{
+			name: "unknown key",
+			key: func(t *testing.T, s *UserService, authRepository *fakeAuthRepository) string {
+				return auth_entity.APIKeyPrefix + "unknown"
+			},
+		},
+		{
+			name: "revoked",
+			key: func(t *testing.T, s *UserService, authRepository *fakeAuthRepository) string {
+				created := newTestAPIKey(t, s)
+				if err := s.RevokeAPIKey(ctx, created.ID); err != nil {
+					t.Fatal(err)
+				}
+				return created.Key
+			},
+		},
+		{
+			name: "expired",
+			key: func(t *testing.T, s *UserService, authRepository *fakeAuthRepository) string {
+				created := newTestAPIKey(t, s)
+				authRepository.apiKeys[created.ID].ExpiresAt = &expired
+				return created.Key
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser("user-1")
+			authRepository := newFakeAuthRepository()
+			s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+			_, err := s.AuthenticateAPIKey(ctx, tt.key(t, s, authRepository))
+			if !errors.Is(err, auth_error.ErrInvalidAPIKey) {
+				t.Fatalf("got %v, want ErrInvalidAPIKey", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKeyRejectsInactiveCreator(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	userRepository := newFakeUserRepository(user)
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+	created := newTestAPIKey(t, s)
+
+	userRepository.users[user.ID].IsDisabled = true
+	_, err := s.AuthenticateAPIKey(ctx, created.Key)
+	if !errors.Is(err, auth_error.ErrAccessRevoked) {
+		t.Fatalf("got %v, want ErrAccessRevoked", err)
+	}
+}
+
+func TestCreateAPIKeyValidatesScopesAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		payload *auth_entity.AddAPIKey
+		want    error
+	}{
+		{"unknown scope", &auth_entity.AddAPIKey{Scopes: []string{"patients:delete"}}, auth_error.ErrInvalidScope},
+		{"key management", &auth_entity.AddAPIKey{Scopes: []string{string(permission_entity.APIKeysManage)}}, auth_error.ErrInvalidScope},
+		{"scope the role lacks", &auth_entity.AddAPIKey{Scopes: []string{string(permission_entity.DiagnosesWrite)}}, auth_error.ErrInvalidScope},
+		{"expiry in the past", &auth_entity.AddAPIKey{Scopes: []string{string(permission_entity.PatientsRead)}, ExpiresAt: &past}, auth_error.ErrInvalidAPIKeyExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser("user-1")
+			authRepository := newFakeAuthRepository()
+			s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+			tt.payload.Name = "reporting"
+			tt.payload.CreatedBy = user.ID
+			if _, err := s.CreateAPIKey(ctx, tt.payload); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if len(authRepository.apiKeys) != 0 {
+				t.Fatal("key created")
+			}
+		})
+	}
+}
+
+func newTestAPIKey(t *testing.T, s *UserService) *auth_entity.CreatedAPIKey {
+	t.Helper()
+	created, err := s.CreateAPIKey(context.Background(), &auth_entity.AddAPIKey{
+		Name:      "reporting",
+		Scopes:    []string{string(permission_entity.PatientsRead)},
+		CreatedBy: "user-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	return created
+}
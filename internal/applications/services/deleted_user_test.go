@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+func newTestNurse(id string) *user_entity.User {
+	user := newTestUser(id)
+	user.NIP = 3032200001001
+	user.Role = user_entity.Nurse
+	return user
+}
+
+func TestActionsOnDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+	actions := map[string]func(s *UserService, userId string) error{
+		"ChangePassword": func(s *UserService, userId string) error {
+			_, err := s.ChangePassword(ctx, &user_entity.ChangePassword{
+				UserID:          userId,
+				CurrentPassword: testPassword,
+				NewPassword:     "Brand-New-Pass1",
+			})
+			return err
+		},
+		"UnlockUser": func(s *UserService, userId string) error {
+			return s.UnlockUser(ctx, userId)
+		},
+		"GetSessions": func(s *UserService, userId string) error {
+			_, err := s.GetSessions(ctx, userId, "")
+			return err
+		},
+		"RevokeUserSessions": func(s *UserService, userId string) error {
+			return s.RevokeUserSessions(ctx, userId)
+		},
+		"ResetPassword": func(s *UserService, userId string) error {
+			_, err := s.ResetPassword(ctx, userId)
+			return err
+		},
+	}
+
+	for name, action := range actions {
+		t.Run(name, func(t *testing.T) {
+			user := newTestNurse("user-1")
+			user.IsDeleted = true
+			s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+
+			if err := action(s, user.ID); !errors.Is(err, user_error.ErrUserNotFound) {
+				t.Fatalf("got %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeleteAndRestoreStaffUser(t *testing.T) {
+	ctx := context.Background()
+	user := newTestNurse("user-1")
+	userRepository := newFakeUserRepository(user)
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+	session := newTestSession(t, s, user)
+
+	if err := s.DeleteStaffUser(ctx, user_entity.Nurse, user.ID); err != nil {
+		t.Fatalf("DeleteStaffUser: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, session.AccessToken); err == nil {
+		t.Fatal("deleted user still authenticated")
+	}
+	if err := s.DeleteStaffUser(ctx, user_entity.Nurse, user.ID); !errors.Is(err, user_error.ErrUserNotFound) {
+		t.Fatalf("deleting twice: got %v, want ErrUserNotFound", err)
+	}
+
+	if err := s.RestoreStaffUser(ctx, user_entity.Nurse, user.ID); err != nil {
+		t.Fatalf("RestoreStaffUser: %v", err)
+	}
+	if userRepository.users[user.ID].IsDeleted {
+		t.Fatal("user not restored")
+	}
+	if err := s.RestoreStaffUser(ctx, user_entity.Nurse, user.ID); !errors.Is(err, user_error.ErrUserNotDeleted) {
+		t.Fatalf("restoring an active user: got %v, want ErrUserNotDeleted", err)
+	}
+}
@@ -0,0 +1,565 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/repositories"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+// The fakes keep just enough state in memory for the service tests. Methods a
+// test does not need fall through to the embedded nil interface and panic.
+
+type fakeUserRepository struct {
+	repositories.UserRepository
+	users           map[string]*user_entity.User
+	passwordHistory map[string][]string
+}
+
+func newFakeUserRepository(users ...*user_entity.User) *fakeUserRepository {
+	r := &fakeUserRepository{
+		users:           map[string]*user_entity.User{},
+		passwordHistory: map[string][]string{},
+	}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepository) GetUserByID(ctx context.Context, id string) (*user_entity.User, error) {
+	user, ok := r.users[id]
+	if !ok || user.IsDeleted {
+		return nil, user_error.ErrUserNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *fakeUserRepository) GetUserByIDIncludingDeleted(ctx context.Context, id string) (*user_entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, user_error.ErrUserNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *fakeUserRepository) DeleteStaffUser(ctx context.Context, userId string) error {
+	r.users[userId].IsDeleted = true
+	return nil
+}
+
+func (r *fakeUserRepository) RestoreUser(ctx context.Context, userId string) error {
+	r.users[userId].IsDeleted = false
+	return nil
+}
+
+func (r *fakeUserRepository) GetUserByNIP(ctx context.Context, userNIP nip.NIP) (*user_entity.User, error) {
+	for _, user := range r.users {
+		if user.NIP == userNIP && !user.IsDeleted {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, user_error.ErrUserNotFound
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, userId, password string, mustChangePassword bool) error {
+	user, ok := r.users[userId]
+	if !ok {
+		return user_error.ErrUserNotFound
+	}
+	user.Password = password
+	user.MustChangePassword = mustChangePassword
+	return nil
+}
+
+func (r *fakeUserRepository) GetPasswordHistory(ctx context.Context, userId string, limit int) ([]string, error) {
+	history := r.passwordHistory[userId]
+	return history[max(0, len(history)-limit):], nil
+}
+
+func (r *fakeUserRepository) AddPasswordHistory(ctx context.Context, userId, password string) error {
+	r.passwordHistory[userId] = append(r.passwordHistory[userId], password)
+	return nil
+}
+
+func (r *fakeUserRepository) VerifyNIP(ctx context.Context, userNIP nip.NIP) (bool, error) {
+	for _, user := range r.users {
+		if user.NIP == userNIP {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AllocateNIP hands out sequences in order, skipping NIPs already in use.
+func (r *fakeUserRepository) AllocateNIP(ctx context.Context, prefix nip.Prefix, gender nip.Gender, year int, month time.Month) (nip.NIP, error) {
+	for sequence := 1; sequence <= nip.MaxSequence; sequence++ {
+		candidate, err := nip.New(prefix, gender, year, month, sequence)
+		if err != nil {
+			return 0, err
+		}
+		if isTaken, _ := r.VerifyNIP(ctx, candidate); !isTaken {
+			return candidate, nil
+		}
+	}
+	return 0, user_error.ErrNIPSequenceExhausted
+}
+
+func (r *fakeUserRepository) CreateITUser(ctx context.Context, payload *user_entity.RegisterITUser) (string, error) {
+	id := fmt.Sprintf("user-%d", len(r.users)+1)
+	r.users[id] = &user_entity.User{
+		ID:                 id,
+		NIP:                payload.NIP,
+		Name:               payload.Name,
+		Password:           payload.Password,
+		Role:               user_entity.IT,
+		MustChangePassword: payload.MustChangePassword,
+	}
+	return id, nil
+}
+
+func (r *fakeUserRepository) CreateStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.RegisterStaffUser) (string, error) {
+	id := fmt.Sprintf("user-%d", len(r.users)+1)
+	r.users[id] = &user_entity.User{ID: id, NIP: payload.NIP, Name: payload.Name, Role: role}
+	return id, nil
+}
+
+func (r *fakeUserRepository) CreateFirstITUser(ctx context.Context, payload *user_entity.RegisterITUser) (string, error) {
+	for _, user := range r.users {
+		if user.Role == user_entity.IT && !user.IsDeleted {
+			return "", user_error.ErrRegistrationClosed
+		}
+	}
+	return r.CreateITUser(ctx, payload)
+}
+
+func (r *fakeUserRepository) DisableITUser(ctx context.Context, userId string) error {
+	if err := r.checkRemainingITUsers(userId); err != nil {
+		return err
+	}
+	r.users[userId].IsDisabled = true
+	return nil
+}
+
+func (r *fakeUserRepository) EnableUser(ctx context.Context, userId string) error {
+	r.users[userId].IsDisabled = false
+	return nil
+}
+
+func (r *fakeUserRepository) DeleteITUser(ctx context.Context, userId string) error {
+	if err := r.checkRemainingITUsers(userId); err != nil {
+		return err
+	}
+	r.users[userId].IsDeleted = true
+	return nil
+}
+
+// checkRemainingITUsers mirrors the last IT user guard of the Postgres
+// repository.
+func (r *fakeUserRepository) checkRemainingITUsers(userId string) error {
+	for id, user := range r.users {
+		if id != userId && user.Role == user_entity.IT && user.IsActive(time.Now()) {
+			return nil
+		}
+	}
+	return user_error.ErrLastITUser
+}
+
+type fakeAuthRepository struct {
+	repositories.AuthRepository
+	refreshTokens map[string]*auth_entity.RefreshToken
+	sessions      map[string]*auth_entity.Session
+	terminated    map[string]bool
+	revokedTokens map[string]bool
+	revokedBefore map[string]time.Time
+	loginAttempts map[nip.NIP]*auth_entity.LoginAttempt
+	mfa           map[string]*auth_entity.MFA
+	recoveryCodes map[string]map[string]bool
+	apiKeys       map[string]*auth_entity.APIKey
+	oidcStates    map[string]*auth_entity.OIDCLoginState
+}
+
+func newFakeAuthRepository() *fakeAuthRepository {
+	return &fakeAuthRepository{
+		refreshTokens: map[string]*auth_entity.RefreshToken{},
+		sessions:      map[string]*auth_entity.Session{},
+		terminated:    map[string]bool{},
+		revokedTokens: map[string]bool{},
+		revokedBefore: map[string]time.Time{},
+		loginAttempts: map[nip.NIP]*auth_entity.LoginAttempt{},
+		mfa:           map[string]*auth_entity.MFA{},
+		recoveryCodes: map[string]map[string]bool{},
+		apiKeys:       map[string]*auth_entity.APIKey{},
+		oidcStates:    map[string]*auth_entity.OIDCLoginState{},
+	}
+}
+
+func (r *fakeAuthRepository) CreateRefreshToken(ctx context.Context, token *auth_entity.RefreshToken) error {
+	r.refreshTokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *fakeAuthRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*auth_entity.RefreshToken, error) {
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok {
+		return nil, auth_error.ErrInvalidRefreshToken
+	}
+	found := *token
+	return &found, nil
+}
+
+func (r *fakeAuthRepository) RotateRefreshToken(ctx context.Context, oldTokenId string, newToken *auth_entity.RefreshToken) error {
+	for _, token := range r.refreshTokens {
+		if token.ID == oldTokenId {
+			if token.RevokedAt != nil {
+				return auth_error.ErrRefreshTokenReused
+			}
+			now := time.Now().UTC()
+			token.RevokedAt = &now
+		}
+	}
+	r.refreshTokens[newToken.TokenHash] = newToken
+	return nil
+}
+
+func (r *fakeAuthRepository) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	now := time.Now().UTC()
+	for _, token := range r.refreshTokens {
+		if token.FamilyID == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepository) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	now := time.Now().UTC()
+	for _, token := range r.refreshTokens {
+		if token.UserID == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// activeRefreshTokens counts the tokens of a family that can still be used.
+func (r *fakeAuthRepository) activeRefreshTokens(familyId string) int {
+	count := 0
+	for _, token := range r.refreshTokens {
+		if token.FamilyID == familyId && token.RevokedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *fakeAuthRepository) RevokeAccessToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error {
+	r.revokedTokens[tokenId] = true
+	return nil
+}
+
+func (r *fakeAuthRepository) IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	return r.revokedTokens[tokenId], nil
+}
+
+func (r *fakeAuthRepository) RevokeUserAccessTokens(ctx context.Context, userId string, revokedBefore time.Time) error {
+	r.revokedBefore[userId] = revokedBefore
+	return nil
+}
+
+func (r *fakeAuthRepository) GetUserTokensRevokedBefore(ctx context.Context, userId string) (time.Time, error) {
+	return r.revokedBefore[userId], nil
+}
+
+func (r *fakeAuthRepository) SaveSession(ctx context.Context, session *auth_entity.Session) error {
+	if r.terminated[session.ID] {
+		return auth_error.ErrSessionTerminated
+	}
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *fakeAuthRepository) GetSessions(ctx context.Context, userId string) ([]*auth_entity.Session, error) {
+	sessions := []*auth_entity.Session{}
+	for id, session := range r.sessions {
+		if session.UserID == userId && !r.terminated[id] {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeAuthRepository) TouchSession(ctx context.Context, sessionId string) (bool, error) {
+	_, ok := r.sessions[sessionId]
+	return ok && !r.terminated[sessionId], nil
+}
+
+func (r *fakeAuthRepository) TerminateSession(ctx context.Context, userId, sessionId string) error {
+	session, ok := r.sessions[sessionId]
+	if !ok || session.UserID != userId || r.terminated[sessionId] {
+		return auth_error.ErrSessionNotFound
+	}
+	r.terminated[sessionId] = true
+	return r.RevokeRefreshTokenFamily(ctx, sessionId)
+}
+
+func (r *fakeAuthRepository) TerminateUserSessions(ctx context.Context, userId string) error {
+	for id, session := range r.sessions {
+		if session.UserID == userId {
+			r.terminated[id] = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepository) GetLoginAttempt(ctx context.Context, userNIP nip.NIP) (*auth_entity.LoginAttempt, error) {
+	attempt, ok := r.loginAttempts[userNIP]
+	if !ok {
+		return &auth_entity.LoginAttempt{NIP: userNIP}, nil
+	}
+	found := *attempt
+	return &found, nil
+}
+
+func (r *fakeAuthRepository) RecordFailedLogin(ctx context.Context, userNIP nip.NIP) (*auth_entity.LoginAttempt, error) {
+	attempt, ok := r.loginAttempts[userNIP]
+	if !ok {
+		attempt = &auth_entity.LoginAttempt{NIP: userNIP}
+		r.loginAttempts[userNIP] = attempt
+	}
+	attempt.FailedCount++
+	found := *attempt
+	return &found, nil
+}
+
+func (r *fakeAuthRepository) LockLogin(ctx context.Context, userNIP nip.NIP, lockedUntil time.Time) error {
+	r.loginAttempts[userNIP].LockedUntil = &lockedUntil
+	return nil
+}
+
+func (r *fakeAuthRepository) ResetLoginAttempts(ctx context.Context, userNIP nip.NIP) error {
+	delete(r.loginAttempts, userNIP)
+	return nil
+}
+
+func (r *fakeAuthRepository) GetMFA(ctx context.Context, userId string) (*auth_entity.MFA, error) {
+	mfa, ok := r.mfa[userId]
+	if !ok {
+		return &auth_entity.MFA{UserID: userId}, nil
+	}
+	found := *mfa
+	return &found, nil
+}
+
+func (r *fakeAuthRepository) UseMFAStep(ctx context.Context, userId string, step int64) (bool, error) {
+	mfa := r.mfa[userId]
+	if mfa.LastUsedStep != nil && *mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = &step
+	return true, nil
+}
+
+func (r *fakeAuthRepository) UseMFARecoveryCode(ctx context.Context, userId, codeHash string) (bool, error) {
+	if !r.recoveryCodes[userId][codeHash] {
+		return false, nil
+	}
+	delete(r.recoveryCodes[userId], codeHash)
+	return true, nil
+}
+
+func (r *fakeAuthRepository) CreateAPIKey(ctx context.Context, apiKey *auth_entity.APIKey) error {
+	apiKey.CreatedAt = time.Now().UTC()
+	stored := *apiKey
+	r.apiKeys[apiKey.ID] = &stored
+	return nil
+}
+
+func (r *fakeAuthRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*auth_entity.APIKey, error) {
+	for _, apiKey := range r.apiKeys {
+		if apiKey.KeyHash == keyHash {
+			found := *apiKey
+			return &found, nil
+		}
+	}
+	return nil, auth_error.ErrInvalidAPIKey
+}
+
+func (r *fakeAuthRepository) RevokeAPIKey(ctx context.Context, apiKeyId string) error {
+	apiKey, ok := r.apiKeys[apiKeyId]
+	if !ok {
+		return auth_error.ErrAPIKeyNotFound
+	}
+	now := time.Now().UTC()
+	apiKey.RevokedAt = &now
+	return nil
+}
+
+func (r *fakeAuthRepository) TouchAPIKey(ctx context.Context, apiKeyId string, usedAt time.Time) error {
+	r.apiKeys[apiKeyId].LastUsedAt = &usedAt
+	return nil
+}
+
+func (r *fakeAuthRepository) CreateOIDCLoginState(ctx context.Context, state *auth_entity.OIDCLoginState) error {
+	r.oidcStates[state.StateHash] = state
+	return nil
+}
+
+func (r *fakeAuthRepository) ConsumeOIDCLoginState(ctx context.Context, stateHash string) (*auth_entity.OIDCLoginState, error) {
+	state, ok := r.oidcStates[stateHash]
+	if !ok {
+		return nil, auth_error.ErrInvalidOIDCState
+	}
+	delete(r.oidcStates, stateHash)
+	return state, nil
+}
+
+// fakeIdentityProvider signs everyone in as nip, provided the code exchange
+// carries the verifier and nonce the login was started with.
+type fakeIdentityProvider struct {
+	nip       nip.NIP
+	trustMFA  bool
+	challenge string
+	nonce     string
+}
+
+func (p *fakeIdentityProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	p.challenge, p.nonce = codeChallenge, nonce
+	return "https://idp.example.com/authorize?state=" + state, nil
+}
+
+func (p *fakeIdentityProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*auth_entity.ExternalIdentity, error) {
+	if helpers.PKCEChallenge(codeVerifier) != p.challenge || nonce != p.nonce {
+		return nil, errors.New("code exchange does not match the login")
+	}
+	return &auth_entity.ExternalIdentity{Subject: p.nip.String(), NIP: p.nip}, nil
+}
+
+func (p *fakeIdentityProvider) AllowedRoles() []user_entity.Role {
+	return []user_entity.Role{user_entity.IT, user_entity.Nurse}
+}
+
+func (p *fakeIdentityProvider) TrustsMFA() bool {
+	return p.trustMFA
+}
+
+// fakePasswordHasher stores passwords with a readable prefix so tests can
+// build users with known passwords without paying for a real hash.
+type fakePasswordHasher struct{}
+
+func (fakePasswordHasher) Hash(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (fakePasswordHasher) Verify(hashedPassword, password string) (bool, error) {
+	return hashedPassword == "hashed:"+password, nil
+}
+
+func (fakePasswordHasher) NeedsRehash(hashedPassword string) bool {
+	return false
+}
+
+func newTestUserService(userRepository *fakeUserRepository, authRepository *fakeAuthRepository) *UserService {
+	passwordPolicy := &helpers.PasswordPolicy{MinLength: 8, MinCharClasses: 3, HistorySize: 5}
+	return NewUserService(userRepository, authRepository, fakePasswordHasher{}, passwordPolicy, nil, "").(*UserService)
+}
+
+type fakePatient struct {
+	*medical_entity.MedicalPatientDetail
+	isDeleted bool
+}
+
+type fakeMedicalRepository struct {
+	repositories.MedicalRepository
+	patients map[nik.NIK]*fakePatient
+}
+
+func newFakeMedicalRepository() *fakeMedicalRepository {
+	return &fakeMedicalRepository{patients: map[nik.NIK]*fakePatient{}}
+}
+
+func (r *fakeMedicalRepository) VerifyIdentityNumber(ctx context.Context, identityNumber nik.NIK) (bool, error) {
+	patient, ok := r.patients[identityNumber]
+	return ok && !patient.isDeleted, nil
+}
+
+func (r *fakeMedicalRepository) CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) error {
+	if _, ok := r.patients[payload.IdentityNumber]; ok {
+		return medical_error.ErrPatientIsDeleted
+	}
+	birthDate, err := time.Parse(time.RFC3339, payload.BirthDate)
+	if err != nil {
+		return err
+	}
+	r.patients[payload.IdentityNumber] = &fakePatient{MedicalPatientDetail: &medical_entity.MedicalPatientDetail{
+		IdentityNumber: payload.IdentityNumber,
+		PhoneNumber:    payload.PhoneNumber,
+		Name:           payload.Name,
+		BirthDate:      birthDate,
+		Gender:         payload.Gender,
+		CardImageURL:   payload.CardImageURL,
+	}}
+	return nil
+}
+
+func (r *fakeMedicalRepository) GetMedicalPatient(ctx context.Context, identityNumber nik.NIK) (*medical_entity.MedicalPatientDetail, error) {
+	patient, ok := r.patients[identityNumber]
+	if !ok || patient.isDeleted {
+		return nil, medical_error.ErrPatientNotFound
+	}
+	detail := *patient.MedicalPatientDetail
+	return &detail, nil
+}
+
+func (r *fakeMedicalRepository) DeletePatient(ctx context.Context, identityNumber nik.NIK) error {
+	patient, ok := r.patients[identityNumber]
+	if !ok || patient.isDeleted {
+		return medical_error.ErrPatientNotFound
+	}
+	patient.isDeleted = true
+	return nil
+}
+
+func (r *fakeMedicalRepository) RestorePatient(ctx context.Context, identityNumber nik.NIK) error {
+	patient, ok := r.patients[identityNumber]
+	if !ok || !patient.isDeleted {
+		return medical_error.ErrPatientNotFound
+	}
+	patient.isDeleted = false
+	return nil
+}
+
+func (r *fakeMedicalRepository) UpdatePatient(ctx context.Context, payload *medical_entity.PatchMedicalPatient) error {
+	patient, ok := r.patients[payload.IdentityNumber]
+	if !ok || patient.isDeleted {
+		return medical_error.ErrPatientNotFound
+	}
+	if payload.BirthDate != nil {
+		birthDate, err := time.Parse(time.RFC3339, *payload.BirthDate)
+		if err != nil {
+			return err
+		}
+		patient.BirthDate = birthDate
+	}
+	if payload.Gender != nil {
+		patient.Gender = *payload.Gender
+	}
+	if payload.Name != nil {
+		patient.Name = *payload.Name
+	}
+	return nil
+}
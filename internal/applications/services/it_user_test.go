@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+func newRegisterITUser() *user_entity.RegisterITUser {
+	return &user_entity.RegisterITUser{
+		Gender:   nip.Female,
+		Name:     "Rina Wati",
+		Password: "Correct-Horse1",
+	}
+}
+
+func TestCreateITUserBootstrap(t *testing.T) {
+	ctx := context.Background()
+	userRepository := newFakeUserRepository()
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	loggedInUser, err := s.CreateITUser(ctx, newRegisterITUser())
+	if err != nil {
+		t.Fatalf("CreateITUser: %v", err)
+	}
+	if loggedInUser.AccessToken == "" {
+		t.Fatal("first IT user not logged in")
+	}
+	created := userRepository.users[loggedInUser.UserID]
+	if created.MustChangePassword || created.NIP.Prefix() != user_entity.IT.NIPPrefix() {
+		t.Fatalf("unexpected first IT user %+v", created)
+	}
+	if len(userRepository.passwordHistory[created.ID]) != 1 {
+		t.Fatal("initial password not recorded in history")
+	}
+
+	if _, err := s.CreateITUser(ctx, newRegisterITUser()); !errors.Is(err, user_error.ErrRegistrationClosed) {
+		t.Fatalf("second self-registration: got %v, want ErrRegistrationClosed", err)
+	}
+}
+
+func TestCreateITUserWithSetupToken(t *testing.T) {
+	ctx := context.Background()
+	userRepository := newFakeUserRepository(newTestUser("user-0"))
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+	s.SetupToken = "setup-token"
+
+	payload := newRegisterITUser()
+	payload.SetupToken = "wrong-token"
+	if _, err := s.CreateITUser(ctx, payload); !errors.Is(err, user_error.ErrRegistrationClosed) {
+		t.Fatalf("wrong setup token: got %v, want ErrRegistrationClosed", err)
+	}
+
+	// With a setup token registration stays open after the first IT user.
+	payload = newRegisterITUser()
+	payload.SetupToken = "setup-token"
+	if _, err := s.CreateITUser(ctx, payload); err != nil {
+		t.Fatalf("CreateITUser: %v", err)
+	}
+}
+
+func TestCreateITUserChecksPasswordPolicy(t *testing.T) {
+	userRepository := newFakeUserRepository()
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	payload := newRegisterITUser()
+	payload.Password = "rinawati"
+	var policyErr *user_error.PasswordPolicyError
+	if _, err := s.CreateITUser(context.Background(), payload); !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a password policy error", err)
+	}
+	if len(userRepository.users) != 0 {
+		t.Fatal("user created")
+	}
+}
+
+func TestAddITUserMustChangePassword(t *testing.T) {
+	userRepository := newFakeUserRepository(newTestUser("user-0"))
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	added, err := s.AddITUser(context.Background(), newRegisterITUser())
+	if err != nil {
+		t.Fatalf("AddITUser: %v", err)
+	}
+	if !userRepository.users[added.ID].MustChangePassword {
+		t.Fatal("added IT user does not have to change the initial password")
+	}
+}
+
+func TestRemoveLastITUser(t *testing.T) {
+	ctx := context.Background()
+	remove := map[string]func(s *UserService, userId string) error{
+		"disable": func(s *UserService, userId string) error { return s.DisableITUser(ctx, userId) },
+		"delete":  func(s *UserService, userId string) error { return s.DeleteITUser(ctx, userId) },
+	}
+
+	for name, removeUser := range remove {
+		t.Run(name, func(t *testing.T) {
+			first := newTestUser("user-1")
+			second := newTestUser("user-2")
+			second.NIP = 6151200001002
+			userRepository := newFakeUserRepository(first, second)
+			s := newTestUserService(userRepository, newFakeAuthRepository())
+			session := newTestSession(t, s, first)
+
+			if err := removeUser(s, first.ID); err != nil {
+				t.Fatalf("removing one of two IT users: %v", err)
+			}
+			if _, err := s.Authenticate(ctx, session.AccessToken); err == nil {
+				t.Fatal("removed IT user still authenticated")
+			}
+
+			if err := removeUser(s, second.ID); !errors.Is(err, user_error.ErrLastITUser) {
+				t.Fatalf("removing the last IT user: got %v, want ErrLastITUser", err)
+			}
+			if !userRepository.users[second.ID].IsActive(time.Now()) {
+				t.Fatal("last IT user removed")
+			}
+		})
+	}
+}
+
+func TestITUserActionsRejectOtherUsers(t *testing.T) {
+	ctx := context.Background()
+	nurse := newTestUser("user-1")
+	nurse.NIP = 3032200001001
+	nurse.Role = user_entity.Nurse
+	deleted := newTestUser("user-2")
+	deleted.NIP = 6151200001002
+	deleted.IsDeleted = true
+	s := newTestUserService(newFakeUserRepository(nurse, deleted, newTestUser("user-3")), newFakeAuthRepository())
+
+	if err := s.DisableITUser(ctx, nurse.ID); !errors.Is(err, user_error.ErrUserIsNotIT) {
+		t.Fatalf("nurse: got %v, want ErrUserIsNotIT", err)
+	}
+	if err := s.DeleteITUser(ctx, deleted.ID); !errors.Is(err, user_error.ErrUserNotFound) {
+		t.Fatalf("deleted user: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestAddITUserAllocatesNIP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	taken, err := nip.New(nip.PrefixIT, nip.Female, now.Year(), now.Month(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := newTestUser("user-0")
+	existing.NIP = taken
+	userRepository := newFakeUserRepository(existing)
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	added, err := s.AddITUser(ctx, newRegisterITUser())
+	if err != nil {
+		t.Fatalf("AddITUser: %v", err)
+	}
+	want, _ := nip.New(nip.PrefixIT, nip.Female, now.Year(), now.Month(), 2)
+	if added.NIP != want {
+		t.Fatalf("got NIP %d, want %d", added.NIP, want)
+	}
+
+	// A NIP given by the client is kept as is.
+	payload := newRegisterITUser()
+	payload.NIP = 6151200001005
+	added, err = s.AddITUser(ctx, payload)
+	if err != nil {
+		t.Fatalf("AddITUser: %v", err)
+	}
+	if added.NIP != 6151200001005 {
+		t.Fatalf("got NIP %d, want the given one", added.NIP)
+	}
+
+	if _, err := s.AddITUser(ctx, payload); !errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		t.Fatalf("duplicate NIP: got %v, want ErrNIPAlreadyExists", err)
+	}
+}
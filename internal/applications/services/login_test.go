@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+func TestLoginLockoutBackoff(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+	wrong := &user_entity.LoginUser{NIP: user.NIP, Password: "wrong-password"}
+
+	for i := 1; i < auth_entity.MaxFailedLoginAttempts; i++ {
+		if _, err := s.LoginUser(ctx, wrong); !errors.Is(err, user_error.ErrInvalidLogin) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidLogin", i, err)
+		}
+		if authRepository.loginAttempts[user.NIP].LockedUntil != nil {
+			t.Fatalf("locked after %d failures", i)
+		}
+	}
+
+	want := []time.Duration{
+		auth_entity.LockoutBaseDuration,
+		2 * auth_entity.LockoutBaseDuration,
+		4 * auth_entity.LockoutBaseDuration,
+	}
+	for i, lockout := range want {
+		// Each lockout has to run out before the next attempt is counted.
+		if attempt := authRepository.loginAttempts[user.NIP]; attempt.LockedUntil != nil {
+			expired := time.Now().UTC().Add(-time.Second)
+			attempt.LockedUntil = &expired
+		}
+
+		before := time.Now().UTC()
+		if _, err := s.LoginUser(ctx, wrong); !errors.Is(err, user_error.ErrInvalidLogin) {
+			t.Fatalf("lockout %d: got %v, want ErrInvalidLogin", i, err)
+		}
+		lockedUntil := authRepository.loginAttempts[user.NIP].LockedUntil
+		if lockedUntil == nil {
+			t.Fatalf("lockout %d: not locked", i)
+		}
+		if got := lockedUntil.Sub(before); got < lockout || got > lockout+time.Second {
+			t.Fatalf("lockout %d: locked for %v, want %v", i, got, lockout)
+		}
+	}
+}
+
+func TestLoginLockoutIsCapped(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	authRepository.loginAttempts[user.NIP] = &auth_entity.LoginAttempt{NIP: user.NIP, FailedCount: 100}
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+	before := time.Now().UTC()
+	_, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: user.NIP, Password: "wrong-password"})
+	if !errors.Is(err, user_error.ErrInvalidLogin) {
+		t.Fatalf("got %v, want ErrInvalidLogin", err)
+	}
+	got := authRepository.loginAttempts[user.NIP].LockedUntil.Sub(before)
+	if got < auth_entity.LockoutMaxDuration || got > auth_entity.LockoutMaxDuration+time.Second {
+		t.Fatalf("locked for %v, want %v", got, auth_entity.LockoutMaxDuration)
+	}
+}
+
+func TestLoginWhileLockedRejectsTheRightPassword(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	lockedUntil := time.Now().UTC().Add(time.Minute)
+	authRepository.loginAttempts[user.NIP] = &auth_entity.LoginAttempt{
+		NIP:         user.NIP,
+		FailedCount: auth_entity.MaxFailedLoginAttempts,
+		LockedUntil: &lockedUntil,
+	}
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+	_, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: user.NIP, Password: testPassword})
+	if !errors.Is(err, user_error.ErrLoginLocked) {
+		t.Fatalf("got %v, want ErrLoginLocked", err)
+	}
+}
+
+func TestLoginResetsFailedAttempts(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	authRepository.loginAttempts[user.NIP] = &auth_entity.LoginAttempt{NIP: user.NIP, FailedCount: 3}
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+	if _, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: user.NIP, Password: testPassword}); err != nil {
+		t.Fatalf("LoginUser: %v", err)
+	}
+	if _, ok := authRepository.loginAttempts[user.NIP]; ok {
+		t.Fatal("failed attempts were not reset")
+	}
+}
+
+func TestLoginCountsUnknownNIPs(t *testing.T) {
+	ctx := context.Background()
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(), authRepository)
+
+	unknown := newTestUser("user-1").NIP
+	_, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: unknown, Password: testPassword})
+	if !errors.Is(err, user_error.ErrInvalidLogin) {
+		t.Fatalf("got %v, want ErrInvalidLogin", err)
+	}
+	if authRepository.loginAttempts[unknown].FailedCount != 1 {
+		t.Fatal("failed attempt for an unknown NIP was not recorded")
+	}
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	os.Setenv("MFA_ENCRYPTION_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err := helpers.NewJWTKeySet(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -2,55 +2,185 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
 
 	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
 	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
 	"github.com/danzBraham/halo-suster/internal/domains/repositories"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
 	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
 )
 
 type MedicalService struct {
 	MedicalRepository repositories.MedicalRepository
+	NIKMode           nik.Mode
 }
 
-func NewMedicalService(medicalRepository repositories.MedicalRepository) interfaces.MedicalService {
-	return &MedicalService{MedicalRepository: medicalRepository}
+func NewMedicalService(medicalRepository repositories.MedicalRepository, nikMode nik.Mode) interfaces.MedicalService {
+	return &MedicalService{
+		MedicalRepository: medicalRepository,
+		NIKMode:           nikMode,
+	}
 }
 
-func (s *MedicalService) CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) error {
+// CreatePatient returns the NIK mismatches it accepted in warn mode as
+// warnings.
+func (s *MedicalService) CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) ([]string, error) {
+	birthDate, err := time.Parse(time.RFC3339, payload.BirthDate)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := s.checkIdentityNumber(payload.IdentityNumber, birthDate, payload.Gender)
+	if err != nil {
+		return nil, err
+	}
+
 	isIdentityNumberExists, err := s.MedicalRepository.VerifyIdentityNumber(ctx, payload.IdentityNumber)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if isIdentityNumberExists {
-		return medical_error.ErrIdentityNumberAlreadyExists
+		return nil, medical_error.ErrIdentityNumberAlreadyExists
 	}
 
 	err = s.MedicalRepository.CreatePatient(ctx, payload)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return warnings, nil
+}
+
+// checkIdentityNumber rejects a NIK that disagrees with the birth date and
+// gender in strict mode. In warn mode the mismatch is logged and returned as a
+// warning instead.
+func (s *MedicalService) checkIdentityNumber(identityNumber nik.NIK, birthDate time.Time, gender medical_entity.Gender) ([]string, error) {
+	err := matchIdentityNumber(identityNumber, birthDate, gender)
+	if err == nil {
+		return nil, nil
+	}
+	if s.NIKMode == nik.ModeStrict {
+		return nil, err
+	}
+	log.Printf("Accepting patient with mismatched NIK: %v", err)
+	return []string{err.Error()}, nil
+}
+
+// matchIdentityNumber checks the birth date and gender encoded in the NIK
+// against the ones submitted with it.
+func matchIdentityNumber(identityNumber nik.NIK, birthDate time.Time, gender medical_entity.Gender) error {
+	components, err := identityNumber.Components()
+	if err != nil {
+		return fmt.Errorf("%w: %v", medical_error.ErrIdentityNumberMismatch, err)
+	}
+
+	y1, m1, d1 := birthDate.Date()
+	y2, m2, d2 := components.BirthDate.Date()
+	if y1 != y2 || m1 != m2 || d1 != d2 {
+		return fmt.Errorf("%w: birth date is %s", medical_error.ErrIdentityNumberMismatch, components.BirthDate.Format(time.DateOnly))
+	}
+
+	if string(components.Gender) != string(gender) {
+		return fmt.Errorf("%w: gender is %s", medical_error.ErrIdentityNumberMismatch, components.Gender)
 	}
 
 	return nil
 }
 
-func (s *MedicalService) GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, error) {
-	patients, err := s.MedicalRepository.GetMedicalPatients(ctx, params)
+func (s *MedicalService) GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, int, error) {
+	patients, total, err := s.MedicalRepository.GetMedicalPatients(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return patients, nil
+	return patients, total, nil
 }
 
-func (s *MedicalService) CreateMedicalRecord(ctx context.Context, payload *medical_entity.AddMedicalRecord) error {
-	isIdentityNumberExists, err := s.MedicalRepository.VerifyIdentityNumber(ctx, payload.IdentityNumber)
+func (s *MedicalService) GetMedicalPatient(ctx context.Context, identityNumber nik.NIK) (*medical_entity.MedicalPatientDetail, error) {
+	return s.MedicalRepository.GetMedicalPatient(ctx, identityNumber)
+}
+
+func (s *MedicalService) UpdatePatient(ctx context.Context, payload *medical_entity.UpdateMedicalPatient) (*medical_entity.MedicalPatientDetail, []string, error) {
+	return s.PatchPatient(ctx, &medical_entity.PatchMedicalPatient{
+		IdentityNumber: payload.IdentityNumber,
+		PhoneNumber:    &payload.PhoneNumber,
+		Name:           &payload.Name,
+		BirthDate:      &payload.BirthDate,
+		Gender:         &payload.Gender,
+		CardImageURL:   &payload.CardImageURL,
+	})
+}
+
+// PatchPatient checks the NIK against the patient as it will be stored once
+// the patch is applied, whenever the patch changes the birth date or gender.
+func (s *MedicalService) PatchPatient(ctx context.Context, payload *medical_entity.PatchMedicalPatient) (*medical_entity.MedicalPatientDetail, []string, error) {
+	var warnings []string
+	if payload.BirthDate != nil || payload.Gender != nil {
+		patient, err := s.MedicalRepository.GetMedicalPatient(ctx, payload.IdentityNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		birthDate, gender := patient.BirthDate, patient.Gender
+		if payload.BirthDate != nil {
+			birthDate, err = time.Parse(time.RFC3339, *payload.BirthDate)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if payload.Gender != nil {
+			gender = *payload.Gender
+		}
+
+		warnings, err = s.checkIdentityNumber(payload.IdentityNumber, birthDate, gender)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err := s.MedicalRepository.UpdatePatient(ctx, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patient, err := s.MedicalRepository.GetMedicalPatient(ctx, payload.IdentityNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return patient, warnings, nil
+}
+
+func (s *MedicalService) DeletePatient(ctx context.Context, identityNumber nik.NIK) error {
+	return s.MedicalRepository.DeletePatient(ctx, identityNumber)
+}
+
+// RestorePatient undeletes a patient so that the identity number can be used
+// again.
+func (s *MedicalService) RestorePatient(ctx context.Context, identityNumber nik.NIK) error {
+	isIdentityNumberExists, err := s.MedicalRepository.VerifyIdentityNumber(ctx, identityNumber)
 	if err != nil {
 		return err
 	}
-	if !isIdentityNumberExists {
+	if isIdentityNumberExists {
+		return medical_error.ErrPatientNotDeleted
+	}
+
+	return s.MedicalRepository.RestorePatient(ctx, identityNumber)
+}
+
+func (s *MedicalService) CreateMedicalRecord(ctx context.Context, payload *medical_entity.AddMedicalRecord) error {
+	// Records cannot be added to a patient that was deleted.
+	_, err := s.MedicalRepository.GetMedicalPatient(ctx, payload.IdentityNumber)
+	if errors.Is(err, medical_error.ErrPatientNotFound) {
 		return medical_error.ErrIdentityNumberIsNotExists
 	}
+	if err != nil {
+		return err
+	}
 
 	err = s.MedicalRepository.CreateMedicalRecord(ctx, payload)
 	if err != nil {
@@ -60,11 +190,11 @@ func (s *MedicalService) CreateMedicalRecord(ctx context.Context, payload *medic
 	return nil
 }
 
-func (s *MedicalService) GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, error) {
-	medicalRecords, err := s.MedicalRepository.GetMedicalRecords(ctx, params)
+func (s *MedicalService) GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, int, error) {
+	medicalRecords, total, err := s.MedicalRepository.GetMedicalRecords(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return medicalRecords, nil
+	return medicalRecords, total, nil
 }
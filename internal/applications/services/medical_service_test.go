@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
+)
+
+// testNIK belongs to a man born on 15 August 1990 in Jawa Barat.
+const testNIK nik.NIK = "3201011508900001"
+
+func newTestPatient() *medical_entity.AddMedicalPatient {
+	return &medical_entity.AddMedicalPatient{
+		IdentityNumber: testNIK,
+		PhoneNumber:    "+6281234567890",
+		Name:           "Budi Santoso",
+		BirthDate:      "1990-08-15T00:00:00Z",
+		Gender:         medical_entity.Male,
+		CardImageURL:   "https://example.com/card.png",
+	}
+}
+
+func TestDeletedPatientMustBeRestored(t *testing.T) {
+	ctx := context.Background()
+	s := NewMedicalService(newFakeMedicalRepository(), nik.ModeStrict)
+
+	if _, err := s.CreatePatient(ctx, newTestPatient()); err != nil {
+		t.Fatalf("CreatePatient: %v", err)
+	}
+	if _, err := s.CreatePatient(ctx, newTestPatient()); !errors.Is(err, medical_error.ErrIdentityNumberAlreadyExists) {
+		t.Fatalf("duplicate CreatePatient: got %v, want ErrIdentityNumberAlreadyExists", err)
+	}
+	if err := s.RestorePatient(ctx, testNIK); !errors.Is(err, medical_error.ErrPatientNotDeleted) {
+		t.Fatalf("RestorePatient of an active patient: got %v, want ErrPatientNotDeleted", err)
+	}
+
+	if err := s.DeletePatient(ctx, testNIK); err != nil {
+		t.Fatalf("DeletePatient: %v", err)
+	}
+	if _, err := s.CreatePatient(ctx, newTestPatient()); !errors.Is(err, medical_error.ErrPatientIsDeleted) {
+		t.Fatalf("CreatePatient over a deleted patient: got %v, want ErrPatientIsDeleted", err)
+	}
+
+	if err := s.RestorePatient(ctx, testNIK); err != nil {
+		t.Fatalf("RestorePatient: %v", err)
+	}
+	if _, err := s.GetMedicalPatient(ctx, testNIK); err != nil {
+		t.Fatalf("GetMedicalPatient after restore: %v", err)
+	}
+}
+
+func TestRestorePatientNotFound(t *testing.T) {
+	s := NewMedicalService(newFakeMedicalRepository(), nik.ModeStrict)
+
+	if err := s.RestorePatient(context.Background(), testNIK); !errors.Is(err, medical_error.ErrPatientNotFound) {
+		t.Fatalf("got %v, want ErrPatientNotFound", err)
+	}
+}
+
+func TestCreatePatientChecksIdentityNumber(t *testing.T) {
+	ctx := context.Background()
+	female := newTestPatient()
+	female.Gender = medical_entity.Female
+
+	strict := NewMedicalService(newFakeMedicalRepository(), nik.ModeStrict)
+	if _, err := strict.CreatePatient(ctx, female); !errors.Is(err, medical_error.ErrIdentityNumberMismatch) {
+		t.Fatalf("strict mode: got %v, want ErrIdentityNumberMismatch", err)
+	}
+
+	warn := NewMedicalService(newFakeMedicalRepository(), nik.ModeWarn)
+	warnings, err := warn.CreatePatient(ctx, female)
+	if err != nil {
+		t.Fatalf("warn mode: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("warn mode: got warnings %q, want one", warnings)
+	}
+
+	warnings, err = warn.CreatePatient(ctx, &medical_entity.AddMedicalPatient{
+		IdentityNumber: "3201011508900002",
+		BirthDate:      "1990-08-15T00:00:00Z",
+		Gender:         medical_entity.Male,
+	})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("matching NIK: got %q, %v", warnings, err)
+	}
+}
+
+func TestPatchPatientChecksMergedRecord(t *testing.T) {
+	ctx := context.Background()
+	female := medical_entity.Female
+	otherBirthDate := "1991-08-15T00:00:00Z"
+	name := "Budi"
+
+	tests := []struct {
+		name    string
+		payload *medical_entity.PatchMedicalPatient
+		wantErr bool
+	}{
+		{"gender", &medical_entity.PatchMedicalPatient{Gender: &female}, true},
+		{"birth date", &medical_entity.PatchMedicalPatient{BirthDate: &otherBirthDate}, true},
+		{"name", &medical_entity.PatchMedicalPatient{Name: &name}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.payload.IdentityNumber = testNIK
+
+			s := NewMedicalService(newFakeMedicalRepository(), nik.ModeStrict)
+			if _, err := s.CreatePatient(ctx, newTestPatient()); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := s.PatchPatient(ctx, tt.payload)
+			if tt.wantErr != errors.Is(err, medical_error.ErrIdentityNumberMismatch) {
+				t.Fatalf("strict mode: got %v", err)
+			}
+
+			s = NewMedicalService(newFakeMedicalRepository(), nik.ModeWarn)
+			if _, err := s.CreatePatient(ctx, newTestPatient()); err != nil {
+				t.Fatal(err)
+			}
+			patient, warnings, err := s.PatchPatient(ctx, tt.payload)
+			if err != nil {
+				t.Fatalf("warn mode: %v", err)
+			}
+			if tt.wantErr != (len(warnings) == 1) {
+				t.Fatalf("warn mode: got warnings %q", warnings)
+			}
+			if patient == nil {
+				t.Fatal("warn mode: patient was not returned")
+			}
+		})
+	}
+}
+
+func TestUpdatePatientChecksIdentityNumber(t *testing.T) {
+	ctx := context.Background()
+	s := NewMedicalService(newFakeMedicalRepository(), nik.ModeStrict)
+	if _, err := s.CreatePatient(ctx, newTestPatient()); err != nil {
+		t.Fatal(err)
+	}
+
+	patient := newTestPatient()
+	_, _, err := s.UpdatePatient(ctx, &medical_entity.UpdateMedicalPatient{
+		IdentityNumber: testNIK,
+		PhoneNumber:    patient.PhoneNumber,
+		Name:           patient.Name,
+		BirthDate:      patient.BirthDate,
+		Gender:         medical_entity.Female,
+		CardImageURL:   patient.CardImageURL,
+	})
+	if !errors.Is(err, medical_error.ErrIdentityNumberMismatch) {
+		t.Fatalf("got %v, want ErrIdentityNumberMismatch", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+// newMFAUser enrolls a user in MFA with a single recovery code and returns
+// the pending token from the password step.
+func newMFAUser(t *testing.T, s *UserService, authRepository *fakeAuthRepository, user *user_entity.User, recoveryCode string) string {
+	t.Helper()
+	secret, err := helpers.EncryptString("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.MFAEnabled = true
+	authRepository.mfa[user.ID] = &auth_entity.MFA{UserID: user.ID, Secret: secret, Enabled: true}
+	authRepository.recoveryCodes[user.ID] = map[string]bool{
+		helpers.HashToken(normalizeRecoveryCode(recoveryCode)): true,
+	}
+
+	loggedInUser, err := s.LoginUser(context.Background(), &user_entity.LoginUser{NIP: user.NIP, Password: testPassword})
+	if err != nil {
+		t.Fatalf("LoginUser: %v", err)
+	}
+	if !loggedInUser.MFARequired || loggedInUser.AccessToken != "" {
+		t.Fatal("password step issued tokens for an MFA user")
+	}
+	return loggedInUser.MFAToken
+}
+
+func TestLoginMFAWithRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+	mfaToken := newMFAUser(t, s, authRepository, user, "ABCD-EFGH-IJKL-MNOP")
+
+	loggedInUser, err := s.LoginMFA(ctx, &auth_entity.LoginMFA{MFAToken: mfaToken, RecoveryCode: "abcd efgh ijkl mnop"})
+	if err != nil {
+		t.Fatalf("LoginMFA: %v", err)
+	}
+	if loggedInUser.AccessToken == "" || loggedInUser.RefreshToken == "" {
+		t.Fatal("expected a token pair")
+	}
+
+	// The pending token is spent once it has been exchanged.
+	_, err = s.LoginMFA(ctx, &auth_entity.LoginMFA{MFAToken: mfaToken, RecoveryCode: "ABCD-EFGH-IJKL-MNOP"})
+	if !errors.Is(err, auth_error.ErrTokenRevoked) {
+		t.Fatalf("reused MFA token: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestLoginMFARecoveryCodesAreSingleUse(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+
+	mfaToken := newMFAUser(t, s, authRepository, user, "ABCD-EFGH-IJKL-MNOP")
+	if _, err := s.LoginMFA(ctx, &auth_entity.LoginMFA{MFAToken: mfaToken, RecoveryCode: "ABCD-EFGH-IJKL-MNOP"}); err != nil {
+		t.Fatalf("LoginMFA: %v", err)
+	}
+
+	loggedInUser, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: user.NIP, Password: testPassword})
+	if err != nil {
+		t.Fatalf("LoginUser: %v", err)
+	}
+	_, err = s.LoginMFA(ctx, &auth_entity.LoginMFA{MFAToken: loggedInUser.MFAToken, RecoveryCode: "ABCD-EFGH-IJKL-MNOP"})
+	if !errors.Is(err, user_error.ErrInvalidMFACode) {
+		t.Fatalf("got %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestLoginMFAWrongCodeCountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+	mfaToken := newMFAUser(t, s, authRepository, user, "ABCD-EFGH-IJKL-MNOP")
+
+	_, err := s.LoginMFA(ctx, &auth_entity.LoginMFA{MFAToken: mfaToken, Code: "000000"})
+	if !errors.Is(err, user_error.ErrInvalidMFACode) {
+		t.Fatalf("got %v, want ErrInvalidMFACode", err)
+	}
+	if authRepository.loginAttempts[user.NIP].FailedCount != 1 {
+		t.Fatal("wrong MFA code was not counted")
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	for _, code := range []string{"ABCD-EFGH-IJKL-MNOP", "abcd-efgh-ijkl-mnop", "ABCD EFGH IJKL MNOP", "abcdefghijklmnop"} {
+		if got := normalizeRecoveryCode(code); got != "ABCDEFGHIJKLMNOP" {
+			t.Errorf("normalizeRecoveryCode(%q) = %q", code, got)
+		}
+	}
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	code, err := generateRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 19 || code[4] != '-' || code[9] != '-' || code[14] != '-' {
+		t.Fatalf("unexpected recovery code format %q", code)
+	}
+	if normalizeRecoveryCode(code) != code[:4]+code[5:9]+code[10:14]+code[15:] {
+		t.Fatalf("recovery code %q does not survive normalization", code)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+)
+
+func completeOIDCLogin(t *testing.T, s *UserService) (*auth_entity.OIDCCallback, error) {
+	t.Helper()
+	ctx := context.Background()
+	login, err := s.BeginOIDCLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin: %v", err)
+	}
+
+	callback := &auth_entity.OIDCCallback{State: login.State, Code: "code", CookieState: login.State}
+	loggedInUser, err := s.CompleteOIDCLogin(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+	if loggedInUser.MFARequired {
+		return callback, errMFARequired
+	}
+	if loggedInUser.AccessToken == "" {
+		t.Fatal("login completed without an access token")
+	}
+	return callback, nil
+}
+
+var errMFARequired = errors.New("MFA required")
+
+func TestCompleteOIDCLoginEnforcesLocalMFA(t *testing.T) {
+	tests := []struct {
+		name       string
+		mfaEnabled bool
+		trustMFA   bool
+		want       error
+	}{
+		{"no MFA", false, false, nil},
+		{"local MFA", true, false, errMFARequired},
+		{"local MFA with trusted IdP", true, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser("user-1")
+			user.MFAEnabled = tt.mfaEnabled
+			s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+			s.IdentityProvider = &fakeIdentityProvider{nip: user.NIP, trustMFA: tt.trustMFA}
+
+			if _, err := completeOIDCLogin(t, s); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteOIDCLoginStateIsSingleUse(t *testing.T) {
+	user := newTestUser("user-1")
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	s.IdentityProvider = &fakeIdentityProvider{nip: user.NIP}
+
+	callback, err := completeOIDCLogin(t, s)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	_, err = s.CompleteOIDCLogin(context.Background(), callback)
+	if !errors.Is(err, auth_error.ErrInvalidOIDCState) {
+		t.Fatalf("replayed state: got %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestCompleteOIDCLoginRejectsInactiveUser(t *testing.T) {
+	user := newTestUser("user-1")
+	user.IsDisabled = true
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	s.IdentityProvider = &fakeIdentityProvider{nip: user.NIP}
+
+	if _, err := completeOIDCLogin(t, s); !errors.Is(err, auth_error.ErrOIDCUserNotAllowed) {
+		t.Fatalf("got %v, want ErrOIDCUserNotAllowed", err)
+	}
+}
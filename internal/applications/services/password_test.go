@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+func TestResetPasswordIssuesPolicyCompliantPassword(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	userRepository := newFakeUserRepository(user)
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(userRepository, authRepository)
+	session := newTestSession(t, s, user)
+
+	reset, err := s.ResetPassword(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	if violations := s.PasswordPolicy.Check("password", reset.TemporaryPassword, user.NIP.String(), user.Name); len(violations) > 0 {
+		t.Fatalf("temporary password breaks the policy: %+v", violations)
+	}
+
+	stored := userRepository.users[user.ID]
+	if !stored.MustChangePassword {
+		t.Fatal("password change not forced on next login")
+	}
+	if stored.Password != "hashed:"+reset.TemporaryPassword {
+		t.Fatal("temporary password not stored")
+	}
+	if history := userRepository.passwordHistory[user.ID]; len(history) != 1 || history[0] != stored.Password {
+		t.Fatalf("temporary password not recorded in history: %v", history)
+	}
+
+	if _, err := s.Authenticate(ctx, session.AccessToken); err == nil {
+		t.Fatal("session survived the password reset")
+	}
+}
+
+func TestResetPasswordRejectsDeletedUser(t *testing.T) {
+	user := newTestUser("user-1")
+	user.IsDeleted = true
+	userRepository := newFakeUserRepository(user)
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	_, err := s.ResetPassword(context.Background(), user.ID)
+	if !errors.Is(err, user_error.ErrUserNotFound) {
+		t.Fatalf("got %v, want ErrUserNotFound", err)
+	}
+	if userRepository.users[user.ID].Password != "hashed:"+testPassword {
+		t.Fatal("password of a deleted user was reset")
+	}
+}
+
+func TestResetPasswordRequiresAccess(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name            string
+		password        string
+		accessExpiresAt *time.Time
+	}{
+		{"never given", "", nil},
+		{"revoked", "", &expired},
+		{"expired", "hashed:" + testPassword, &expired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestNurse("user-1")
+			user.Password = tt.password
+			user.AccessExpiresAt = tt.accessExpiresAt
+			userRepository := newFakeUserRepository(user)
+			s := newTestUserService(userRepository, newFakeAuthRepository())
+
+			_, err := s.ResetPassword(context.Background(), user.ID)
+			if !errors.Is(err, user_error.ErrUserHasNoAccess) {
+				t.Fatalf("got %v, want ErrUserHasNoAccess", err)
+			}
+			if userRepository.users[user.ID].Password != tt.password {
+				t.Fatal("password of a user without access was reset")
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyRejectsReusedPassword(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	userRepository := newFakeUserRepository(user)
+	userRepository.passwordHistory[user.ID] = []string{"hashed:Previous-Pass1"}
+	s := newTestUserService(userRepository, newFakeAuthRepository())
+
+	for _, password := range []string{testPassword, "Previous-Pass1"} {
+		err := s.checkPasswordPolicy(ctx, "newPassword", password, user)
+		var policyErr *user_error.PasswordPolicyError
+		if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 || policyErr.Violations[0].Rule != "history" {
+			t.Fatalf("%q: got %v, want a history violation", password, err)
+		}
+	}
+
+	if err := s.checkPasswordPolicy(ctx, "newPassword", "Brand-New-Pass1", user); err != nil {
+		t.Fatalf("fresh password rejected: %v", err)
+	}
+}
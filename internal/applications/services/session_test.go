@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+func sessionID(t *testing.T, accessToken string) string {
+	t.Helper()
+	credential, err := helpers.VerifyJWT(accessToken)
+	if err != nil {
+		t.Fatalf("VerifyJWT: %v", err)
+	}
+	return credential.SessionID
+}
+
+func TestGetSessionsMarksCurrent(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	first := newTestSession(t, s, user)
+	newTestSession(t, s, user)
+
+	current := sessionID(t, first.AccessToken)
+	sessions, err := s.GetSessions(ctx, user.ID, current)
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Current != (session.ID == current) {
+			t.Fatalf("session %s marked current %v", session.ID, session.Current)
+		}
+	}
+}
+
+func TestTerminateSessionEndsOnlyThatSession(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	ended := newTestSession(t, s, user)
+	kept := newTestSession(t, s, user)
+
+	// Warm the session cache so termination has to invalidate it.
+	if _, err := s.Authenticate(ctx, ended.AccessToken); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if err := s.TerminateSession(ctx, user.ID, sessionID(t, ended.AccessToken)); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	if _, err := s.Authenticate(ctx, ended.AccessToken); !errors.Is(err, auth_error.ErrSessionTerminated) {
+		t.Fatalf("terminated session: got %v, want ErrSessionTerminated", err)
+	}
+	if _, err := s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: ended.RefreshToken}); err == nil {
+		t.Fatal("terminated session refreshed")
+	}
+
+	if _, err := s.Authenticate(ctx, kept.AccessToken); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+	if _, err := s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: kept.RefreshToken}); err != nil {
+		t.Fatalf("other session refresh: %v", err)
+	}
+}
+
+func TestTerminateSessionOfAnotherUser(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	other := newTestUser("user-2")
+	other.NIP = 6151200001002
+	s := newTestUserService(newFakeUserRepository(user, other), newFakeAuthRepository())
+	session := newTestSession(t, s, other)
+
+	err := s.TerminateSession(ctx, user.ID, sessionID(t, session.AccessToken))
+	if !errors.Is(err, auth_error.ErrSessionNotFound) {
+		t.Fatalf("got %v, want ErrSessionNotFound", err)
+	}
+	if _, err := s.Authenticate(ctx, session.AccessToken); err != nil {
+		t.Fatalf("session of the other user ended: %v", err)
+	}
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	session := newTestSession(t, s, user)
+
+	credential, err := s.Authenticate(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	err = s.LogoutUser(ctx, credential, &auth_entity.LogoutPayload{RefreshToken: session.RefreshToken})
+	if err != nil {
+		t.Fatalf("LogoutUser: %v", err)
+	}
+
+	if _, err := s.Authenticate(ctx, session.AccessToken); err == nil {
+		t.Fatal("access token still valid after logout")
+	}
+	if _, err := s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: session.RefreshToken}); err == nil {
+		t.Fatal("refresh token still valid after logout")
+	}
+}
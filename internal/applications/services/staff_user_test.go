@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+func TestCreateStaffUserIssuesSessionToken(t *testing.T) {
+	ctx := context.Background()
+	userRepository := newFakeUserRepository()
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(userRepository, authRepository)
+
+	created, err := s.CreateStaffUser(ctx, user_entity.Doctor, &user_entity.RegisterStaffUser{
+		Gender: nip.Male,
+		Name:   "Budi Santoso",
+	})
+	if err != nil {
+		t.Fatalf("CreateStaffUser: %v", err)
+	}
+	if created.NIP.Prefix() != nip.PrefixDoctor {
+		t.Fatalf("got NIP %d for a doctor", created.NIP)
+	}
+
+	stored := userRepository.users[created.UserID]
+	if stored.Role != user_entity.Doctor || stored.HasAccess(time.Now()) {
+		t.Fatalf("unexpected staff user %+v", stored)
+	}
+
+	credential, err := helpers.VerifyJWT(created.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyJWT: %v", err)
+	}
+	if session := authRepository.sessions[credential.SessionID]; session == nil || session.UserID != created.UserID {
+		t.Fatalf("token is not bound to a session of the new user: %+v", credential)
+	}
+
+	// The token only works once the user has been given access.
+	if _, err := s.Authenticate(ctx, created.AccessToken); err == nil {
+		t.Fatal("token of a user without access was accepted")
+	}
+}
+
+func TestLoginUserChecksRole(t *testing.T) {
+	ctx := context.Background()
+	nurse := newTestNurse("user-1")
+	s := newTestUserService(newFakeUserRepository(nurse), newFakeAuthRepository())
+
+	tests := []struct {
+		role user_entity.Role
+		want error
+	}{
+		{user_entity.Nurse, nil},
+		{user_entity.Doctor, user_error.ErrUserRoleMismatch},
+	}
+	for _, tt := range tests {
+		_, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: nurse.NIP, Password: testPassword, Role: tt.role})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s login: got %v, want %v", tt.role, err, tt.want)
+		}
+	}
+
+	doctor := newTestUser("user-2")
+	doctor.NIP = 1011200001001
+	doctor.Role = user_entity.Doctor
+	_, err := s.LoginUser(ctx, &user_entity.LoginUser{NIP: doctor.NIP, Password: testPassword, Role: user_entity.Nurse})
+	if !errors.Is(err, user_error.ErrUserIsNotNurse) {
+		t.Fatalf("nurse login with a doctor NIP: got %v, want ErrUserIsNotNurse", err)
+	}
+}
+
+func TestStaffActionsCheckRole(t *testing.T) {
+	ctx := context.Background()
+	nurse := newTestNurse("user-1")
+	s := newTestUserService(newFakeUserRepository(nurse), newFakeAuthRepository())
+
+	if err := s.DeleteStaffUser(ctx, user_entity.Doctor, nurse.ID); !errors.Is(err, user_error.ErrUserRoleMismatch) {
+		t.Fatalf("doctor route: got %v, want ErrUserRoleMismatch", err)
+	}
+
+	it := newTestUser("user-2")
+	s = newTestUserService(newFakeUserRepository(it), newFakeAuthRepository())
+	if err := s.DeleteStaffUser(ctx, user_entity.Nurse, it.ID); !errors.Is(err, user_error.ErrUserIsNotNurse) {
+		t.Fatalf("nurse route: got %v, want ErrUserIsNotNurse", err)
+	}
+}
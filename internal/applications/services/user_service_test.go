@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+const testPassword = "Correct-Horse1"
+
+func newTestUser(id string) *user_entity.User {
+	return &user_entity.User{
+		ID:       id,
+		NIP:      6151200001001,
+		Name:     "Test User",
+		Password: "hashed:" + testPassword,
+		Role:     user_entity.IT,
+	}
+}
+
+// newTestSession logs the user in on a fresh session and returns its refresh
+// token.
+func newTestSession(t *testing.T, s *UserService, user *user_entity.User) *user_entity.LoggedInUser {
+	t.Helper()
+	loggedInUser, err := s.createLoggedInUser(context.Background(), user, auth_entity.SessionClient{})
+	if err != nil {
+		t.Fatalf("createLoggedInUser: %v", err)
+	}
+	return loggedInUser
+}
+
+func refreshFamily(t *testing.T, authRepository *fakeAuthRepository, refreshToken string) string {
+	t.Helper()
+	token, ok := authRepository.refreshTokens[helpers.HashToken(refreshToken)]
+	if !ok {
+		t.Fatal("refresh token not stored")
+	}
+	return token.FamilyID
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	authRepository := newFakeAuthRepository()
+	s := newTestUserService(newFakeUserRepository(user), authRepository)
+	session := newTestSession(t, s, user)
+
+	refreshed, err := s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: session.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if refreshed.RefreshToken == session.RefreshToken || refreshed.AccessToken == "" {
+		t.Fatal("expected a new token pair")
+	}
+
+	_, err = s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: session.RefreshToken})
+	if !errors.Is(err, auth_error.ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token: got %v, want ErrRefreshTokenReused", err)
+	}
+	if n := authRepository.activeRefreshTokens(refreshFamily(t, authRepository, session.RefreshToken)); n != 0 {
+		t.Fatalf("reuse left %d tokens of the family active", n)
+	}
+}
+
+func TestRefreshTokenRejectsInactiveUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(user *user_entity.User)
+	}{
+		{"deleted", func(user *user_entity.User) { user.IsDeleted = true }},
+		{"disabled", func(user *user_entity.User) { user.IsDisabled = true }},
+		{"access revoked", func(user *user_entity.User) { user.Password = "" }},
+		{"access expired", func(user *user_entity.User) {
+			expired := time.Now().UTC().Add(-time.Second)
+			user.AccessExpiresAt = &expired
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser("user-1")
+			authRepository := newFakeAuthRepository()
+			s := newTestUserService(newFakeUserRepository(user), authRepository)
+			session := newTestSession(t, s, user)
+
+			tt.change(user)
+
+			_, err := s.RefreshToken(context.Background(), &auth_entity.RefreshTokenPayload{RefreshToken: session.RefreshToken})
+			if !errors.Is(err, auth_error.ErrInvalidRefreshToken) {
+				t.Fatalf("got %v, want ErrInvalidRefreshToken", err)
+			}
+			if n := authRepository.activeRefreshTokens(refreshFamily(t, authRepository, session.RefreshToken)); n != 0 {
+				t.Fatalf("%d tokens of the family are still active", n)
+			}
+		})
+	}
+}
+
+func TestRevokeUserSessionsRejectsTokensFromTheSameSecond(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+
+	// A token without a sid is only covered by the user wide cutoff.
+	legacyToken, err := helpers.CreateJWT(auth_entity.AccessTokenTTL, user.ID, "", user.Role)
+	if err != nil {
+		t.Fatalf("CreateJWT: %v", err)
+	}
+	session := newTestSession(t, s, user)
+	if _, err := s.Authenticate(ctx, session.AccessToken); err != nil {
+		t.Fatalf("Authenticate before revocation: %v", err)
+	}
+
+	if err := s.RevokeUserSessions(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+
+	if _, err := s.Authenticate(ctx, legacyToken); !errors.Is(err, auth_error.ErrTokenRevoked) {
+		t.Errorf("token without sid: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := s.Authenticate(ctx, session.AccessToken); !errors.Is(err, auth_error.ErrSessionTerminated) {
+		t.Errorf("session token: got %v, want ErrSessionTerminated", err)
+	}
+
+	newSession := newTestSession(t, s, user)
+	if _, err := s.Authenticate(ctx, newSession.AccessToken); err != nil {
+		t.Errorf("login after revocation: %v", err)
+	}
+}
+
+func TestAuthenticateRejectsInactiveUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(user *user_entity.User)
+	}{
+		{"deleted", func(user *user_entity.User) { user.IsDeleted = true }},
+		{"access revoked", func(user *user_entity.User) { user.Password = "" }},
+		{"access expired", func(user *user_entity.User) {
+			expired := time.Now().UTC().Add(-time.Second)
+			user.AccessExpiresAt = &expired
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser("user-1")
+			s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+			session := newTestSession(t, s, user)
+
+			tt.change(user)
+
+			_, err := s.Authenticate(context.Background(), session.AccessToken)
+			if !errors.Is(err, auth_error.ErrAccessRevoked) {
+				t.Fatalf("got %v, want ErrAccessRevoked", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateStopsAtAccessExpiry(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser("user-1")
+	expiresAt := time.Now().UTC().Add(50 * time.Millisecond)
+	user.AccessExpiresAt = &expiresAt
+	s := newTestUserService(newFakeUserRepository(user), newFakeAuthRepository())
+	session := newTestSession(t, s, user)
+
+	// The first call caches the user, expiry must still be checked against
+	// the clock on every request.
+	if _, err := s.Authenticate(ctx, session.AccessToken); err != nil {
+		t.Fatalf("Authenticate before expiry: %v", err)
+	}
+	time.Sleep(time.Until(expiresAt))
+
+	if _, err := s.Authenticate(ctx, session.AccessToken); !errors.Is(err, auth_error.ErrAccessRevoked) {
+		t.Fatalf("Authenticate after expiry: got %v, want ErrAccessRevoked", err)
+	}
+	_, err := s.RefreshToken(ctx, &auth_entity.RefreshTokenPayload{RefreshToken: session.RefreshToken})
+	if !errors.Is(err, auth_error.ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshToken after expiry: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
@@ -0,0 +1,161 @@
+package auth_entity
+
+import (
+	"time"
+
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
+
+const (
+	AccessTokenTTL  = 2 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+
+	// How long a "not revoked" answer from the denylist may be served from
+	// memory before Postgres is asked again.
+	DenylistCacheTTL = 30 * time.Second
+
+	// How long an authenticated user lookup is reused before the user is
+	// loaded again. Writes to the user invalidate it immediately.
+	UserCacheTTL = 30 * time.Second
+
+	// After MaxFailedLoginAttempts wrong passwords a NIP is locked for
+	// LockoutBaseDuration, doubling with every further failure up to
+	// LockoutMaxDuration.
+	MaxFailedLoginAttempts = 5
+	LockoutBaseDuration    = time.Minute
+	LockoutMaxDuration     = time.Hour
+
+	MFAIssuer            = "Halo Suster"
+	MFATokenTTL          = 5 * time.Minute
+	MFARecoveryCodeCount = 10
+
+	// API keys look like APIKeyPrefix + random bytes; the first
+	// APIKeyDisplayLength characters are kept in clear so keys can be told
+	// apart in listings. APIKeyLastUsedInterval throttles last_used_at writes.
+	APIKeyPrefix           = "hs_"
+	APIKeyDisplayLength    = 11
+	APIKeyLastUsedInterval = time.Minute
+
+	// How long a user has to finish signing in at the identity provider.
+	OIDCLoginStateTTL = 10 * time.Minute
+)
+
+// A session is one login on one device. It shares its ID with the refresh
+// token family it was issued with, and access tokens carry it as "sid".
+type Session struct {
+	ID           string    `json:"sessionId"`
+	UserID       string    `json:"userId"`
+	UserAgent    string    `json:"userAgent"`
+	IPAddress    string    `json:"ipAddress"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Current      bool      `json:"current"`
+}
+
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	FamilyID  string     `json:"familyId"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string        `json:"refreshToken" validate:"required"`
+	Client       SessionClient `json:"-"`
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type LoginAttempt struct {
+	NIP         nip.NIP    `json:"nip"`
+	FailedCount int        `json:"failedCount"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+}
+
+type MFA struct {
+	UserID       string `json:"userId"`
+	Secret       string `json:"-"`
+	Enabled      bool   `json:"enabled"`
+	LastUsedStep *int64 `json:"-"`
+}
+
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type MFACode struct {
+	UserID string `json:"userId"`
+	Code   string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type MFARecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type LoginMFA struct {
+	MFAToken     string        `json:"mfaToken" validate:"required"`
+	Code         string        `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string        `json:"recoveryCode" validate:"required_without=Code"`
+	Client       SessionClient `json:"-"`
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"createdBy"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type AddAPIKey struct {
+	Name      string     `json:"name" validate:"required,min=3,max=50"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedBy string     `json:"-"`
+}
+
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+type OIDCLoginState struct {
+	StateHash    string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+type OIDCLogin struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+type OIDCCallback struct {
+	State       string        `json:"state" validate:"required"`
+	Code        string        `json:"code" validate:"required"`
+	CookieState string        `json:"-" validate:"required,eqfield=State"`
+	Client      SessionClient `json:"-"`
+}
+
+type ExternalIdentity struct {
+	Subject string
+	NIP     nip.NIP
+}
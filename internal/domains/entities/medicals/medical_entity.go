@@ -1,6 +1,11 @@
 package medical_entity
 
-import "time"
+import (
+	"time"
+
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
 
 type Gender string
 
@@ -10,41 +15,86 @@ const (
 )
 
 type AddMedicalPatient struct {
-	IdentityNumber int    `json:"identityNumber" validate:"required,identitynumber"`
-	PhoneNumber    string `json:"phoneNumber" validate:"required,min=10,max=15,startswith=+62"`
-	Name           string `json:"name" validate:"required,min=3,max=30"`
-	BirthDate      string `json:"birthDate" validate:"required,iso8601date"`
-	Gender         Gender `json:"gender" validate:"required,oneof=male female"`
-	CardImageURL   string `json:"identityCardScanImg" validate:"required,imageurl"`
+	IdentityNumber nik.NIK `json:"identityNumber" validate:"required,identitynumber"`
+	PhoneNumber    string  `json:"phoneNumber" validate:"required,min=10,max=15,startswith=+62"`
+	Name           string  `json:"name" validate:"required,min=3,max=30"`
+	BirthDate      string  `json:"birthDate" validate:"required,iso8601date"`
+	Gender         Gender  `json:"gender" validate:"required,oneof=male female"`
+	CardImageURL   string  `json:"identityCardScanImg" validate:"required,imageurl"`
+	CreatedBy      string  `json:"-"`
+	APIKeyID       string  `json:"-"`
 }
 
 type MedicalPatient struct {
-	IdentityNumber int       `json:"identityNumber"`
-	PhoneNumber    string    `json:"phoneNumber"`
-	Name           string    `json:"name"`
-	BirthDate      time.Time `json:"birthDate"`
-	Gender         Gender    `json:"gender"`
-	CreatedAt      time.Time `json:"createdAt"`
+	IdentityNumber nik.NIK       `json:"identityNumber"`
+	PhoneNumber    string        `json:"phoneNumber"`
+	Name           string        `json:"name"`
+	BirthDate      time.Time     `json:"birthDate"`
+	Gender         Gender        `json:"gender"`
+	Province       *nik.Province `json:"province,omitempty"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+type MedicalPatientDetail struct {
+	IdentityNumber nik.NIK       `json:"identityNumber"`
+	PhoneNumber    string        `json:"phoneNumber"`
+	Name           string        `json:"name"`
+	BirthDate      time.Time     `json:"birthDate"`
+	Gender         Gender        `json:"gender"`
+	CardImageURL   string        `json:"identityCardScanImg"`
+	Province       *nik.Province `json:"province,omitempty"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
+// UpdateMedicalPatient replaces every editable field. The identity number
+// comes from the path and cannot be changed.
+type UpdateMedicalPatient struct {
+	IdentityNumber nik.NIK `json:"-"`
+	PhoneNumber    string  `json:"phoneNumber" validate:"required,min=10,max=15,startswith=+62"`
+	Name           string  `json:"name" validate:"required,min=3,max=30"`
+	BirthDate      string  `json:"birthDate" validate:"required,iso8601date"`
+	Gender         Gender  `json:"gender" validate:"required,oneof=male female"`
+	CardImageURL   string  `json:"identityCardScanImg" validate:"required,imageurl"`
+}
+
+// PatchMedicalPatient only changes the fields that are present.
+type PatchMedicalPatient struct {
+	IdentityNumber nik.NIK `json:"-"`
+	PhoneNumber    *string `json:"phoneNumber" validate:"omitempty,min=10,max=15,startswith=+62"`
+	Name           *string `json:"name" validate:"omitempty,min=3,max=30"`
+	BirthDate      *string `json:"birthDate" validate:"omitempty,iso8601date"`
+	Gender         *Gender `json:"gender" validate:"omitempty,oneof=male female"`
+	CardImageURL   *string `json:"identityCardScanImg" validate:"omitempty,imageurl"`
 }
 
 type MedicalPatientParams struct {
 	IdentityNumber string
-	Limit          string
-	Offset         string
+	Limit          int
+	Offset         int
 	Name           string
 	PhoneNumber    string
-	CreatedAt      string
+	ProvinceCode   string
+	Gender         Gender
+	BirthDateFrom  *time.Time
+	BirthDateTo    *time.Time
+	MinAge         *int
+	MaxAge         *int
+	SortBy         string
+	Order          string
 }
 
 type AddMedicalRecord struct {
-	IdentityNumber int    `json:"identityNumber" validate:"required,identitynumber"`
-	Symptoms       string `json:"symptoms" validate:"required,min=1,max=2000"`
-	Medications    string `json:"medications" validate:"required,min=1,max=2000"`
-	UserID         string `json:"userId" validate:"required"`
+	IdentityNumber nik.NIK `json:"identityNumber" validate:"required,identitynumber"`
+	Symptoms       string  `json:"symptoms" validate:"required,min=1,max=2000"`
+	Medications    string  `json:"medications" validate:"required,min=1,max=2000"`
+	Diagnosis      string  `json:"diagnosis" validate:"max=2000"`
+	UserID         string  `json:"userId" validate:"required"`
+	APIKeyID       string  `json:"-"`
 }
 
 type IdentityDetail struct {
-	IdentityNumber int       `json:"identityNumber"`
+	IdentityNumber nik.NIK   `json:"identityNumber"`
 	PhoneNumber    string    `json:"phoneNumber"`
 	Name           string    `json:"name"`
 	BirthDate      time.Time `json:"birthDate"`
@@ -53,15 +103,17 @@ type IdentityDetail struct {
 }
 
 type CreatedByDetail struct {
-	NIP    int    `json:"nip"`
-	Name   string `json:"name"`
-	UserID string `json:"userId"`
+	NIP      nip.NIP `json:"nip"`
+	Name     string  `json:"name"`
+	UserID   string  `json:"userId"`
+	APIKeyID *string `json:"apiKeyId,omitempty"`
 }
 
 type MedicalRecord struct {
 	IdentityDetail  IdentityDetail  `json:"identityDetail"`
 	Symptoms        string          `json:"symptoms"`
 	Medications     string          `json:"medications"`
+	Diagnosis       string          `json:"diagnosis,omitempty"`
 	CreatedAt       time.Time       `json:"createdAt"`
 	CreatedByDetail CreatedByDetail `json:"createdBy"`
 }
@@ -70,7 +122,7 @@ type MedicalRecordParams struct {
 	IdentityNumber string
 	UserID         string
 	NIP            string
-	Limit          string
-	Offset         string
+	Limit          int
+	Offset         int
 	CreatedAt      string
 }
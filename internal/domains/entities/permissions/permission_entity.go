@@ -0,0 +1,63 @@
+package permission_entity
+
+import user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+
+type Permission string
+
+const (
+	UsersRead      Permission = "users:read"
+	UsersWrite     Permission = "users:write"
+	PatientsRead   Permission = "patients:read"
+	PatientsWrite  Permission = "patients:write"
+	RecordsRead    Permission = "records:read"
+	RecordsWrite   Permission = "records:write"
+	DiagnosesWrite Permission = "diagnoses:write"
+	ImagesUpload   Permission = "images:upload"
+	APIKeysManage  Permission = "apikeys:manage"
+)
+
+var RolePermissions = map[user_entity.Role][]Permission{
+	user_entity.IT: {
+		UsersRead,
+		UsersWrite,
+		PatientsRead,
+		PatientsWrite,
+		RecordsRead,
+		RecordsWrite,
+		ImagesUpload,
+		APIKeysManage,
+	},
+	user_entity.Nurse: {
+		PatientsRead,
+		PatientsWrite,
+		RecordsRead,
+		RecordsWrite,
+		ImagesUpload,
+	},
+	user_entity.Doctor: {
+		PatientsRead,
+		PatientsWrite,
+		RecordsRead,
+		RecordsWrite,
+		DiagnosesWrite,
+		ImagesUpload,
+	},
+	user_entity.Pharmacist: {
+		PatientsRead,
+		RecordsRead,
+	},
+	user_entity.Registrar: {
+		PatientsRead,
+		PatientsWrite,
+		ImagesUpload,
+	},
+}
+
+func HasPermission(role user_entity.Role, permission Permission) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package permission_entity
+
+import (
+	"testing"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+)
+
+var allPermissions = []Permission{
+	UsersRead,
+	UsersWrite,
+	PatientsRead,
+	PatientsWrite,
+	RecordsRead,
+	RecordsWrite,
+	DiagnosesWrite,
+	ImagesUpload,
+	APIKeysManage,
+}
+
+func TestHasPermission(t *testing.T) {
+	granted := map[user_entity.Role][]Permission{
+		user_entity.IT:         {UsersRead, UsersWrite, PatientsRead, PatientsWrite, RecordsRead, RecordsWrite, ImagesUpload, APIKeysManage},
+		user_entity.Nurse:      {PatientsRead, PatientsWrite, RecordsRead, RecordsWrite, ImagesUpload},
+		user_entity.Doctor:     {PatientsRead, PatientsWrite, RecordsRead, RecordsWrite, DiagnosesWrite, ImagesUpload},
+		user_entity.Pharmacist: {PatientsRead, RecordsRead},
+		user_entity.Registrar:  {PatientsRead, PatientsWrite, ImagesUpload},
+		"":                     {},
+		"janitor":              {},
+	}
+
+	for role, permissions := range granted {
+		want := map[Permission]bool{}
+		for _, permission := range permissions {
+			want[permission] = true
+		}
+
+		for _, permission := range allPermissions {
+			t.Run(string(role)+"/"+string(permission), func(t *testing.T) {
+				if got := HasPermission(role, permission); got != want[permission] {
+					t.Fatalf("HasPermission(%q, %q) = %v, want %v", role, permission, got, want[permission])
+				}
+			})
+		}
+	}
+}
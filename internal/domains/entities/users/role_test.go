@@ -0,0 +1,37 @@
+package user_entity
+
+import (
+	"testing"
+
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
+
+func TestRole(t *testing.T) {
+	tests := []struct {
+		role    Role
+		prefix  nip.Prefix
+		isValid bool
+		isStaff bool
+	}{
+		{IT, nip.PrefixIT, true, false},
+		{Nurse, nip.PrefixNurse, true, true},
+		{Doctor, nip.PrefixDoctor, true, true},
+		{Pharmacist, nip.PrefixPharmacist, true, true},
+		{Registrar, nip.PrefixRegistrar, true, true},
+		{"admin", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			if got := tt.role.NIPPrefix(); got != tt.prefix {
+				t.Errorf("NIPPrefix() = %d, want %d", got, tt.prefix)
+			}
+			if got := tt.role.IsValid(); got != tt.isValid {
+				t.Errorf("IsValid() = %v, want %v", got, tt.isValid)
+			}
+			if got := tt.role.IsStaff(); got != tt.isStaff {
+				t.Errorf("IsStaff() = %v, want %v", got, tt.isStaff)
+			}
+		})
+	}
+}
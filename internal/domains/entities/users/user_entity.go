@@ -1,45 +1,114 @@
 package user_entity
 
-import "time"
+import (
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
 
 type Role string
 
 const (
-	IT    Role = "it"
-	Nurse Role = "nurse"
+	IT         Role = "it"
+	Nurse      Role = "nurse"
+	Doctor     Role = "doctor"
+	Pharmacist Role = "pharmacist"
+	Registrar  Role = "registrar"
 )
 
+var nipPrefixes = map[Role]nip.Prefix{
+	IT:         nip.PrefixIT,
+	Nurse:      nip.PrefixNurse,
+	Doctor:     nip.PrefixDoctor,
+	Pharmacist: nip.PrefixPharmacist,
+	Registrar:  nip.PrefixRegistrar,
+}
+
+func (r Role) NIPPrefix() nip.Prefix {
+	return nipPrefixes[r]
+}
+
+func (r Role) IsValid() bool {
+	_, ok := nipPrefixes[r]
+	return ok
+}
+
+// IsStaff reports whether the role is clinical staff. Staff are registered by
+// an IT user and only get a password once they are given access.
+func (r Role) IsStaff() bool {
+	return r.IsValid() && r != IT
+}
+
 type User struct {
-	ID        string    `json:"id"`
-	NIP       int       `json:"nip"`
-	Name      string    `json:"name"`
-	Password  string    `json:"password"`
-	Role      Role      `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 string     `json:"id"`
+	NIP                nip.NIP    `json:"nip"`
+	Name               string     `json:"name"`
+	Password           string     `json:"password"`
+	Role               Role       `json:"role"`
+	MustChangePassword bool       `json:"must_change_password"`
+	MFAEnabled         bool       `json:"mfa_enabled"`
+	IsDisabled         bool       `json:"is_disabled"`
+	IsDeleted          bool       `json:"is_deleted"`
+	AccessExpiresAt    *time.Time `json:"access_expires_at"`
+	CreatedAt          time.Time  `json:"created_at"`
 }
 
+// HasAccess reports whether the user has been given a password to log in with
+// and that access has not expired by now.
+func (u *User) HasAccess(now time.Time) bool {
+	if u.AccessExpiresAt != nil && !now.Before(*u.AccessExpiresAt) {
+		return false
+	}
+	return u.Password != ""
+}
+
+// IsActive reports whether the user may hold a session at now.
+func (u *User) IsActive(now time.Time) bool {
+	return !u.IsDeleted && !u.IsDisabled && u.HasAccess(now)
+}
+
+// When NIP is left out the server allocates the next free one for Gender and
+// the current month.
 type RegisterITUser struct {
-	NIP      int    `json:"nip" validate:"required,nip"`
-	Name     string `json:"name" validate:"required,min=5,max=50"`
-	Password string `json:"password" validate:"required,min=5,max=33"`
+	NIP      nip.NIP                   `json:"nip" validate:"omitempty,nip"`
+	Gender   nip.Gender                `json:"gender" validate:"required_without=NIP"`
+	Name     string                    `json:"name" validate:"required,min=5,max=50"`
+	Password string                    `json:"password" validate:"required,min=5,max=33"`
+	Client   auth_entity.SessionClient `json:"-"`
+
+	// SetupToken is only read by the self-registration endpoint, users added
+	// by another IT user have to replace their initial password.
+	SetupToken         string `json:"-"`
+	MustChangePassword bool   `json:"-"`
 }
 
-type RegisterNurseUser struct {
-	NIP          int    `json:"nip" validate:"required,nip"`
-	Name         string `json:"name" validate:"required,min=5,max=50"`
-	CardImageURL string `json:"identityCardScanImg" validate:"required,imageurl"`
+type RegisterStaffUser struct {
+	NIP          nip.NIP                   `json:"nip" validate:"omitempty,nip"`
+	Gender       nip.Gender                `json:"gender" validate:"required_without=NIP"`
+	Name         string                    `json:"name" validate:"required,min=5,max=50"`
+	CardImageURL string                    `json:"identityCardScanImg" validate:"required,imageurl"`
+	Client       auth_entity.SessionClient `json:"-"`
 }
 
+// LoginUser is a password login. Role is set by the role specific login routes
+// and restricts them to NIPs of that role.
 type LoginUser struct {
-	NIP      int    `json:"nip" validate:"required,nip"`
-	Password string `json:"password" validate:"required,min=5,max=33"`
+	NIP      nip.NIP                   `json:"nip" validate:"required,nip"`
+	Password string                    `json:"password" validate:"required,min=5,max=33"`
+	Role     Role                      `json:"-"`
+	Client   auth_entity.SessionClient `json:"-"`
 }
 
 type LoggedInUser struct {
-	UserID      string `json:"userId"`
-	NIP         int    `json:"nip"`
-	Name        string `json:"name"`
-	AccessToken string `json:"accessToken"`
+	UserID             string  `json:"userId"`
+	NIP                nip.NIP `json:"nip"`
+	Name               string  `json:"name"`
+	AccessToken        string  `json:"accessToken"`
+	RefreshToken       string  `json:"refreshToken,omitempty"`
+	MustChangePassword bool    `json:"mustChangePassword"`
+	MFARequired        bool    `json:"mfaRequired,omitempty"`
+	MFAToken           string  `json:"mfaToken,omitempty"`
 }
 
 type UserQueryParams struct {
@@ -50,22 +119,46 @@ type UserQueryParams struct {
 	NIP       string
 	Role      string
 	CreatedAt string
+	Deleted   bool
+	NIPYear   int
+	NIPMonth  time.Month
+	Gender    nip.Gender
 }
 
 type UserList struct {
-	ID        string    `json:"userId"`
-	NIP       int       `json:"nip"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        string     `json:"userId"`
+	NIP       nip.NIP    `json:"nip"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type UpdateITUser struct {
+	UserID string  `json:"userId"`
+	NIP    nip.NIP `json:"nip" validate:"required,nip"`
+	Name   string  `json:"name" validate:"required,min=5,max=50"`
+}
+
+type UpdateStaffUser struct {
+	UserID string  `json:"userId"`
+	NIP    nip.NIP `json:"nip" validate:"required,nip"`
+	Name   string  `json:"name" validate:"required,min=5,max=50"`
+}
+
+type GiveAccessStaffUser struct {
+	UserID          string     `json:"userId"`
+	Password        string     `json:"password" validate:"required,min=5,max=33"`
+	AccessExpiresAt *time.Time `json:"accessExpiresAt"`
 }
 
-type UpdateNurseUser struct {
-	UserID string `json:"userId"`
-	NIP    int    `json:"nip" validate:"required,nip"`
-	Name   string `json:"name" validate:"required,min=5,max=50"`
+type ChangePassword struct {
+	UserID          string                    `json:"userId"`
+	CurrentPassword string                    `json:"currentPassword" validate:"required"`
+	NewPassword     string                    `json:"newPassword" validate:"required,min=5,max=33,nefield=CurrentPassword"`
+	Client          auth_entity.SessionClient `json:"-"`
 }
 
-type GiveAccessNurseUser struct {
-	UserID   string `json:"userId"`
-	Password string `json:"password" validate:"required,min=5,max=33"`
+type ResetPassword struct {
+	UserID            string `json:"userId"`
+	TemporaryPassword string `json:"temporaryPassword"`
 }
@@ -0,0 +1,34 @@
+package user_entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsActive(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Second)
+	after := now.Add(time.Second)
+
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"active", User{Password: "hashed"}, true},
+		{"no password", User{}, false},
+		{"deleted", User{Password: "hashed", IsDeleted: true}, false},
+		{"disabled", User{Password: "hashed", IsDisabled: true}, false},
+		{"access expires later", User{Password: "hashed", AccessExpiresAt: &after}, true},
+		{"access expires now", User{Password: "hashed", AccessExpiresAt: &now}, false},
+		{"access expired", User{Password: "hashed", AccessExpiresAt: &before}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.IsActive(now); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
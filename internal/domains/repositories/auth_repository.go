@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
+
+type AuthRepository interface {
+	CreateRefreshToken(ctx context.Context, token *auth_entity.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*auth_entity.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenId string, newToken *auth_entity.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string) error
+	RevokeUserRefreshTokens(ctx context.Context, userId string) error
+	RevokeAccessToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error)
+	RevokeUserAccessTokens(ctx context.Context, userId string, revokedBefore time.Time) error
+	GetUserTokensRevokedBefore(ctx context.Context, userId string) (revokedBefore time.Time, err error)
+	GetLoginAttempt(ctx context.Context, nip nip.NIP) (*auth_entity.LoginAttempt, error)
+	RecordFailedLogin(ctx context.Context, nip nip.NIP) (*auth_entity.LoginAttempt, error)
+	LockLogin(ctx context.Context, nip nip.NIP, lockedUntil time.Time) error
+	ResetLoginAttempts(ctx context.Context, nip nip.NIP) error
+	GetMFA(ctx context.Context, userId string) (*auth_entity.MFA, error)
+	SetMFASecret(ctx context.Context, userId, secret string) error
+	EnableMFA(ctx context.Context, userId string, recoveryCodeHashes []string) error
+	DisableMFA(ctx context.Context, userId string) error
+	UseMFAStep(ctx context.Context, userId string, step int64) (bool, error)
+	UseMFARecoveryCode(ctx context.Context, userId, codeHash string) (bool, error)
+	SaveSession(ctx context.Context, session *auth_entity.Session) error
+	GetSessions(ctx context.Context, userId string) ([]*auth_entity.Session, error)
+	TouchSession(ctx context.Context, sessionId string) (bool, error)
+	TerminateSession(ctx context.Context, userId, sessionId string) error
+	TerminateUserSessions(ctx context.Context, userId string) error
+	CreateOIDCLoginState(ctx context.Context, state *auth_entity.OIDCLoginState) error
+	ConsumeOIDCLoginState(ctx context.Context, stateHash string) (*auth_entity.OIDCLoginState, error)
+	CreateAPIKey(ctx context.Context, apiKey *auth_entity.APIKey) error
+	GetAPIKeys(ctx context.Context) ([]*auth_entity.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*auth_entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, apiKeyId string) error
+	TouchAPIKey(ctx context.Context, apiKeyId string, usedAt time.Time) error
+}
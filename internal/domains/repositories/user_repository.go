@@ -2,18 +2,32 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
 )
 
 type UserRepository interface {
-	VerifyNIP(ctx context.Context, nip int) (bool, error)
+	VerifyNIP(ctx context.Context, nip nip.NIP) (bool, error)
+	AllocateNIP(ctx context.Context, prefix nip.Prefix, gender nip.Gender, year int, month time.Month) (nip.NIP, error)
 	CreateITUser(ctx context.Context, payload *user_entity.RegisterITUser) (userId string, err error)
-	CreateNurseUser(ctx context.Context, payload *user_entity.RegisterNurseUser) (userId string, err error)
-	GetUserByNIP(ctx context.Context, nip int) (user *user_entity.User, err error)
+	CreateFirstITUser(ctx context.Context, payload *user_entity.RegisterITUser) (userId string, err error)
+	CreateStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.RegisterStaffUser) (userId string, err error)
+	GetUserByNIP(ctx context.Context, nip nip.NIP) (user *user_entity.User, err error)
 	GetUserByID(ctx context.Context, id string) (user *user_entity.User, err error)
-	GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, error)
-	UpdateNurseUser(ctx context.Context, payload *user_entity.UpdateNurseUser) error
-	DeleteNurseUser(ctx context.Context, userId string) error
-	GiveAccessNurseUser(ctx context.Context, payload *user_entity.GiveAccessNurseUser) error
+	GetUserByIDIncludingDeleted(ctx context.Context, id string) (user *user_entity.User, err error)
+	GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, int, error)
+	UpdateITUser(ctx context.Context, payload *user_entity.UpdateITUser) error
+	DisableITUser(ctx context.Context, userId string) error
+	EnableUser(ctx context.Context, userId string) error
+	DeleteITUser(ctx context.Context, userId string) error
+	UpdateStaffUser(ctx context.Context, payload *user_entity.UpdateStaffUser) error
+	DeleteStaffUser(ctx context.Context, userId string) error
+	RestoreUser(ctx context.Context, userId string) error
+	GiveAccessStaffUser(ctx context.Context, payload *user_entity.GiveAccessStaffUser) error
+	RevokeAccessStaffUser(ctx context.Context, userId string) error
+	UpdatePassword(ctx context.Context, userId, password string, mustChangePassword bool) error
+	GetPasswordHistory(ctx context.Context, userId string, limit int) ([]string, error)
+	AddPasswordHistory(ctx context.Context, userId, password string) error
 }
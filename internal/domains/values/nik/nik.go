@@ -0,0 +1,197 @@
+package nik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A NIK is the 16 digit Indonesian identity number, laid out as PP RR DD
+// DDMMYY SSSS: province, regency and district codes, the holder's date of
+// birth with 40 added to the day for women, and a sequence. No province code
+// starts with 0, so every valid NIK can also be written as a JSON number.
+type NIK string
+
+const Length = 16
+
+var ErrInvalidNIK = errors.New("invalid NIK")
+
+// Format controls how a NIK is written to JSON. Input accepts either form.
+type Format int
+
+const (
+	// FormatNumber is the default and keeps the output older clients expect.
+	FormatNumber Format = iota
+	FormatString
+)
+
+var format = FormatNumber
+
+// Mode controls what happens when a NIK disagrees with the demographics it is
+// submitted with: ModeWarn logs the mismatch and ModeStrict rejects it.
+type Mode int
+
+const (
+	ModeWarn Mode = iota
+	ModeStrict
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "warn":
+		return ModeWarn, nil
+	case "strict":
+		return ModeStrict, nil
+	}
+	return 0, fmt.Errorf("invalid NIK validation mode %q", s)
+}
+
+type Gender string
+
+const (
+	Male   Gender = "male"
+	Female Gender = "female"
+)
+
+// femaleDayOffset is added to the day of birth of women.
+const femaleDayOffset = 40
+
+type Components struct {
+	ProvinceCode string
+	RegencyCode  string
+	DistrictCode string
+	BirthDate    time.Time
+	Gender       Gender
+	Sequence     string
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "", "number":
+		return FormatNumber, nil
+	case "string":
+		return FormatString, nil
+	}
+	return 0, fmt.Errorf("invalid NIK format %q", s)
+}
+
+func SetFormat(f Format) {
+	format = f
+}
+
+func Parse(s string) (NIK, error) {
+	n := NIK(s)
+	if err := n.Validate(); err != nil {
+		return "", err
+	}
+	return n, nil
+}
+
+func (n NIK) String() string {
+	return string(n)
+}
+
+func (n NIK) Validate() error {
+	if !isDigits(string(n)) || len(n) != Length || n[0] == '0' {
+		return ErrInvalidNIK
+	}
+	return nil
+}
+
+// Components decodes the region codes, date of birth and gender. The two digit
+// year is placed in the latest century that does not put it in the future.
+func (n NIK) Components() (*Components, error) {
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+	s := string(n)
+
+	day, _ := strconv.Atoi(s[6:8])
+	month, _ := strconv.Atoi(s[8:10])
+	year, _ := strconv.Atoi(s[10:12])
+
+	gender := Male
+	if day > femaleDayOffset {
+		gender = Female
+		day -= femaleDayOffset
+	}
+
+	now := time.Now()
+	year += 2000
+	if year > now.Year() {
+		year -= 100
+	}
+
+	birthDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if birthDate.Day() != day || int(birthDate.Month()) != month || birthDate.After(now) {
+		return nil, ErrInvalidNIK
+	}
+
+	return &Components{
+		ProvinceCode: s[0:2],
+		RegencyCode:  s[0:4],
+		DistrictCode: s[0:6],
+		BirthDate:    birthDate,
+		Gender:       gender,
+		Sequence:     s[12:],
+	}, nil
+}
+
+// Province returns the province the NIK was issued in, or nil when the code
+// is not in the table.
+func (n NIK) Province() *Province {
+	if len(n) < 2 {
+		return nil
+	}
+	return LookupProvince(string(n[:2]))
+}
+
+// MarshalJSON fails in number mode for a value that is not a valid NIK, rather
+// than writing it with a different JSON type.
+func (n NIK) MarshalJSON() ([]byte, error) {
+	if format == FormatString {
+		return json.Marshal(string(n))
+	}
+	if err := n.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot marshal %q as a NIK number: %w", string(n), err)
+	}
+	return []byte(n), nil
+}
+
+func (n *NIK) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*n = NIK(s)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	if !isDigits(number.String()) {
+		return fmt.Errorf("cannot unmarshal %s into NIK", data)
+	}
+	*n = NIK(number)
+	return nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
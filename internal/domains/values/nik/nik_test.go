@@ -0,0 +1,164 @@
+package nik
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"3201011508900001", true},
+		{"0101011508900001", false},
+		{"320101150890000", false},
+		{"32010115089000011", false},
+		{"32010115089000a1", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if n.String() != tt.input {
+					t.Fatalf("round trip gave %s", n)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("accepted as %s", n)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	defer SetFormat(FormatNumber)
+
+	tests := []struct {
+		format Format
+		nik    NIK
+		want   string
+	}{
+		{FormatString, "3201011508900001", `"3201011508900001"`},
+		{FormatNumber, "3201011508900001", `3201011508900001`},
+	}
+
+	for _, tt := range tests {
+		SetFormat(tt.format)
+		data, err := json.Marshal(tt.nik)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("format %d: got %s, want %s", tt.format, data, tt.want)
+		}
+
+		var n NIK
+		if err := json.Unmarshal(data, &n); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if n != tt.nik {
+			t.Errorf("Unmarshal(%s) = %s, want %s", data, n, tt.nik)
+		}
+	}
+}
+
+func TestMarshalJSONNumberRejectsInvalid(t *testing.T) {
+	for _, n := range []NIK{"", "0101011508900001", "32010115089000a1"} {
+		if data, err := json.Marshal(n); err == nil {
+			t.Errorf("%q written as %s", n, data)
+		}
+	}
+}
+
+func TestUnmarshalJSONRejectsNonDigits(t *testing.T) {
+	for _, input := range []string{`-3201011508900001`, `3.2e15`, `true`} {
+		var n NIK
+		if err := json.Unmarshal([]byte(input), &n); err == nil {
+			t.Errorf("accepted %s as %s", input, n)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for input, want := range map[string]Format{"": FormatNumber, "number": FormatNumber, "string": FormatString} {
+		got, err := ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %d, %v", input, got, err)
+		}
+	}
+	if _, err := ParseFormat("int"); err == nil {
+		t.Error("accepted int")
+	}
+}
+
+func TestComponents(t *testing.T) {
+	c, err := NIK("3201015508900001").Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Components{
+		ProvinceCode: "32",
+		RegencyCode:  "3201",
+		DistrictCode: "320101",
+		BirthDate:    time.Date(1990, time.August, 15, 0, 0, 0, 0, time.UTC),
+		Gender:       Female,
+		Sequence:     "0001",
+	}
+	if *c != want {
+		t.Fatalf("got %+v, want %+v", *c, want)
+	}
+
+	c, err = NIK("3201011508050001").Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Gender != Male || c.BirthDate.Year() != 2005 {
+		t.Fatalf("got %s born %s, want a man born in 2005", c.Gender, c.BirthDate)
+	}
+}
+
+func TestComponentsRejectsInvalidDates(t *testing.T) {
+	for _, n := range []NIK{
+		"3201013102900001",
+		"3201017102900001",
+		"3201011513900001",
+		"3201010008900001",
+		"320101",
+	} {
+		if c, err := n.Components(); err == nil {
+			t.Errorf("%s decoded as %+v", n, *c)
+		}
+	}
+}
+
+func TestProvince(t *testing.T) {
+	if p := NIK("3201011508900001").Province(); p == nil || p.Code != "32" || p.Name != "Jawa Barat" {
+		t.Fatalf("got %+v, want Jawa Barat", p)
+	}
+	if p := NIK("9901011508900001").Province(); p != nil {
+		t.Fatalf("got %+v for an unknown code", p)
+	}
+	if p := NIK("3").Province(); p != nil {
+		t.Fatalf("got %+v for a short NIK", p)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for input, want := range map[string]Mode{"": ModeWarn, "warn": ModeWarn, "strict": ModeStrict} {
+		got, err := ParseMode(input)
+		if err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %d, %v", input, got, err)
+		}
+	}
+	if _, err := ParseMode("off"); err == nil {
+		t.Error("accepted off")
+	}
+}
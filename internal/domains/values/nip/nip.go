@@ -0,0 +1,211 @@
+package nip
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A NIP is laid out as PPP G YYYY MM SSS[SS]: a role prefix, a gender digit,
+// the year and month the user was registered and a 3 to 5 digit sequence.
+type NIP int64
+
+type Prefix int
+
+const (
+	PrefixIT         Prefix = 615
+	PrefixNurse      Prefix = 303
+	PrefixDoctor     Prefix = 101
+	PrefixPharmacist Prefix = 202
+	PrefixRegistrar  Prefix = 404
+)
+
+var prefixes = map[Prefix]bool{
+	PrefixIT:         true,
+	PrefixNurse:      true,
+	PrefixDoctor:     true,
+	PrefixPharmacist: true,
+	PrefixRegistrar:  true,
+}
+
+type Gender int
+
+const (
+	Male   Gender = 1
+	Female Gender = 2
+)
+
+func ParseGender(s string) (Gender, error) {
+	switch s {
+	case "male":
+		return Male, nil
+	case "female":
+		return Female, nil
+	}
+	return 0, fmt.Errorf("invalid gender %q", s)
+}
+
+func (g Gender) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+func (g *Gender) UnmarshalText(text []byte) error {
+	gender, err := ParseGender(string(text))
+	if err != nil {
+		return err
+	}
+	*g = gender
+	return nil
+}
+
+func (g Gender) String() string {
+	switch g {
+	case Male:
+		return "male"
+	case Female:
+		return "female"
+	}
+	return strconv.Itoa(int(g))
+}
+
+const (
+	MinYear      = 2000
+	MaxSequence  = 99999
+	minLength    = 13
+	maxLength    = 15
+	prefixLength = 3
+)
+
+var ErrInvalidNIP = errors.New("invalid NIP")
+
+// New builds a NIP from its components. The sequence is zero padded to three
+// digits and may grow to five.
+func New(prefix Prefix, gender Gender, year int, month time.Month, sequence int) (NIP, error) {
+	if sequence < 1 || sequence > MaxSequence {
+		return 0, ErrInvalidNIP
+	}
+	return Parse(fmt.Sprintf("%03d%d%04d%02d%03d", prefix, gender, year, int(month), sequence))
+}
+
+type Components struct {
+	Prefix   Prefix
+	Gender   Gender
+	Year     int
+	Month    time.Month
+	Sequence string
+}
+
+// Parse reads and validates a NIP. Years after the current one are rejected.
+func Parse(s string) (NIP, error) {
+	if _, err := split(s); err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidNIP
+	}
+	return NIP(n), nil
+}
+
+func split(s string) (*Components, error) {
+	if len(s) < minLength || len(s) > maxLength {
+		return nil, ErrInvalidNIP
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return nil, ErrInvalidNIP
+		}
+	}
+
+	prefix, _ := strconv.Atoi(s[:prefixLength])
+	gender, _ := strconv.Atoi(s[3:4])
+	year, _ := strconv.Atoi(s[4:8])
+	month, _ := strconv.Atoi(s[8:10])
+
+	c := &Components{
+		Prefix:   Prefix(prefix),
+		Gender:   Gender(gender),
+		Year:     year,
+		Month:    time.Month(month),
+		Sequence: s[10:],
+	}
+
+	if !prefixes[c.Prefix] {
+		return nil, ErrInvalidNIP
+	}
+	if c.Gender != Male && c.Gender != Female {
+		return nil, ErrInvalidNIP
+	}
+	if c.Year < MinYear || c.Year > time.Now().Year() {
+		return nil, ErrInvalidNIP
+	}
+	if c.Month < time.January || c.Month > time.December {
+		return nil, ErrInvalidNIP
+	}
+	return c, nil
+}
+
+func (n NIP) String() string {
+	return strconv.FormatInt(int64(n), 10)
+}
+
+func (n NIP) Validate() error {
+	_, err := split(n.String())
+	return err
+}
+
+func (n NIP) Components() (*Components, error) {
+	return split(n.String())
+}
+
+// Prefix returns the role prefix, or 0 when the NIP is too short to have one.
+func (n NIP) Prefix() Prefix {
+	s := n.String()
+	if len(s) < prefixLength {
+		return 0
+	}
+	prefix, _ := strconv.Atoi(s[:prefixLength])
+	return Prefix(prefix)
+}
+
+func (n NIP) Value() (driver.Value, error) {
+	return n.String(), nil
+}
+
+func (n *NIP) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case int64:
+		*n = NIP(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into NIP", src)
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into NIP: %w", s, err)
+	}
+	*n = NIP(value)
+	return nil
+}
+
+func (n NIP) MarshalJSON() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+func (n *NIP) UnmarshalJSON(data []byte) error {
+	var value int64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*n = NIP(value)
+	return nil
+}
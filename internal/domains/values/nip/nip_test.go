@@ -0,0 +1,160 @@
+package nip
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	nextYear := strconv.Itoa(time.Now().Year() + 1)
+
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"6151202401001", true},
+		{"3032200012999", true},
+		{"101120240112345", true},
+		{"615120240100", false},
+		{"6151202401000001", false},
+		{"615120240100a", false},
+		{"9991202401001", false},
+		{"6153202401001", false},
+		{"6151199901001", false},
+		{"6151" + nextYear + "01001", false},
+		{"6151202400001", false},
+		{"6151202413001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				if n.String() != tt.input {
+					t.Fatalf("round trip gave %s", n)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("accepted as %d", n)
+			}
+		})
+	}
+}
+
+func TestComponents(t *testing.T) {
+	c, err := NIP(3032202403042).Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Components{Prefix: PrefixNurse, Gender: Female, Year: 2024, Month: time.March, Sequence: "042"}
+	if *c != want {
+		t.Fatalf("got %+v, want %+v", *c, want)
+	}
+
+	if prefix := NIP(3032202403042).Prefix(); prefix != PrefixNurse {
+		t.Fatalf("got prefix %d", prefix)
+	}
+	if prefix := NIP(12).Prefix(); prefix != 0 {
+		t.Fatalf("short NIP has prefix %d", prefix)
+	}
+}
+
+func TestNew(t *testing.T) {
+	n, err := New(PrefixIT, Male, 2024, time.January, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6151202401007 {
+		t.Fatalf("got %d", n)
+	}
+
+	n, err = New(PrefixDoctor, Female, 2024, time.December, MaxSequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 101220241299999 {
+		t.Fatalf("got %d", n)
+	}
+
+	for _, sequence := range []int{0, MaxSequence + 1} {
+		if _, err := New(PrefixIT, Male, 2024, time.January, sequence); err == nil {
+			t.Errorf("sequence %d accepted", sequence)
+		}
+	}
+}
+
+func TestParseGender(t *testing.T) {
+	for input, want := range map[string]Gender{"male": Male, "female": Female} {
+		got, err := ParseGender(input)
+		if err != nil || got != want {
+			t.Errorf("ParseGender(%q) = %v, %v", input, got, err)
+		}
+		if got.String() != input {
+			t.Errorf("%v.String() = %q", got, got.String())
+		}
+	}
+	for _, input := range []string{"", "Male", "other"} {
+		if _, err := ParseGender(input); err == nil {
+			t.Errorf("ParseGender(%q) accepted", input)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(NIP(6151202401001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "6151202401001" {
+		t.Fatalf("got %s", data)
+	}
+
+	var decoded NIP
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != 6151202401001 {
+		t.Fatalf("got %d", decoded)
+	}
+}
+
+func TestScan(t *testing.T) {
+	for _, src := range []interface{}{"6151202401001", []byte("6151202401001"), int64(6151202401001)} {
+		var n NIP
+		if err := n.Scan(src); err != nil || n != 6151202401001 {
+			t.Errorf("Scan(%T) = %d, %v", src, n, err)
+		}
+	}
+
+	var n NIP
+	if err := n.Scan(3.5); err == nil {
+		t.Fatal("float scanned")
+	}
+	if value, _ := NIP(6151202401001).Value(); value != "6151202401001" {
+		t.Fatalf("Value() = %v", value)
+	}
+}
+
+func TestGenderJSON(t *testing.T) {
+	data, err := json.Marshal(Female)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"female"` {
+		t.Fatalf("got %s", data)
+	}
+
+	var gender Gender
+	if err := json.Unmarshal([]byte(`"male"`), &gender); err != nil || gender != Male {
+		t.Fatalf("got %v, %v", gender, err)
+	}
+	if err := json.Unmarshal([]byte(`"other"`), &gender); err == nil {
+		t.Fatal("invalid gender decoded")
+	}
+}
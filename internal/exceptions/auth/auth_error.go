@@ -3,6 +3,24 @@ package auth_error
 import "errors"
 
 var (
-	ErrInvalidToken  = errors.New("Invalid token")
-	ErrUnknownClaims = errors.New("Unknown claims type")
+	ErrInvalidToken           = errors.New("Invalid token")
+	ErrUnknownClaims          = errors.New("Unknown claims type")
+	ErrInvalidRefreshToken    = errors.New("Invalid refresh token")
+	ErrRefreshTokenReused     = errors.New("Refresh token has already been used")
+	ErrTokenRevoked           = errors.New("Token has been revoked")
+	ErrAccessRevoked          = errors.New("User no longer has access")
+	ErrPermissionDenied       = errors.New("Permission denied")
+	ErrPasswordChangeRequired = errors.New("Password must be changed before continuing")
+	ErrSessionNotFound        = errors.New("Session not found")
+	ErrSessionTerminated      = errors.New("Session has been terminated")
+	ErrOIDCDisabled           = errors.New("Single sign-on is not configured")
+	ErrInvalidOIDCState       = errors.New("Invalid or expired single sign-on state")
+	ErrOIDCLoginFailed        = errors.New("Single sign-on failed")
+	ErrOIDCUserNotAllowed     = errors.New("No user with access is linked to this identity")
+	ErrInvalidAPIKey          = errors.New("Invalid API key")
+	ErrAPIKeyNotFound         = errors.New("API key not found")
+	ErrInvalidScope           = errors.New("Unknown scope or scope not held by the key owner")
+	ErrInvalidAPIKeyExpiry    = errors.New("API key expiry must be in the future")
+	ErrInvalidCSRFToken       = errors.New("Missing or invalid CSRF token")
+	ErrUserSessionRequired    = errors.New("This endpoint requires a user session")
 )
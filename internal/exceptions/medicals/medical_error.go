@@ -5,4 +5,8 @@ import "errors"
 var (
 	ErrIdentityNumberAlreadyExists = errors.New("identity number already exists")
 	ErrIdentityNumberIsNotExists   = errors.New("identity number is not exists")
+	ErrPatientNotFound             = errors.New("patient not found")
+	ErrIdentityNumberMismatch      = errors.New("identity number does not match patient")
+	ErrPatientIsDeleted            = errors.New("identity number belongs to a deleted patient")
+	ErrPatientNotDeleted           = errors.New("patient is not deleted")
 )
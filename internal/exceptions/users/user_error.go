@@ -3,10 +3,36 @@ package user_error
 import "errors"
 
 var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrNIPAlreadyExists = errors.New("NIP already exists")
-	ErrNIPIsNotExists   = errors.New("NIP is not exists")
-	ErrUserIsNotIT      = errors.New("user is not IT")
-	ErrUserIsNotNurse   = errors.New("user is not a nurse")
-	ErrInvalidPassword  = errors.New("invalid password")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrNIPAlreadyExists     = errors.New("NIP already exists")
+	ErrUserIsNotIT          = errors.New("user is not IT")
+	ErrUserIsNotNurse       = errors.New("user is not a nurse")
+	ErrUserRoleMismatch     = errors.New("user does not have this role")
+	ErrUnknownRole          = errors.New("unknown staff role")
+	ErrInvalidPassword      = errors.New("invalid password")
+	ErrInvalidLogin         = errors.New("invalid NIP or password")
+	ErrLoginLocked          = errors.New("too many failed login attempts, try again later")
+	ErrInvalidMFACode       = errors.New("invalid MFA code")
+	ErrMFANotEnrolled       = errors.New("MFA is not enrolled")
+	ErrMFAAlreadyEnabled    = errors.New("MFA is already enabled")
+	ErrLastITUser           = errors.New("cannot remove the last active IT user")
+	ErrRegistrationClosed   = errors.New("IT self-registration is closed")
+	ErrUserNotDeleted       = errors.New("user is not deleted")
+	ErrInvalidAccessExpiry  = errors.New("access expiry must be in the future")
+	ErrUserHasNoAccess      = errors.New("user has no access, give access instead")
+	ErrNIPSequenceExhausted = errors.New("no NIP left to allocate this month")
 )
+
+type PasswordPolicyViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type PasswordPolicyError struct {
+	Violations []PasswordPolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the password policy"
+}
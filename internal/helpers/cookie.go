@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessTokenCookie  = "Authorization"
+	RefreshTokenCookie = "refresh_token"
+	CSRFTokenCookie    = "csrf_token"
+	CSRFTokenHeader    = "X-CSRF-Token"
+	OIDCStateCookie    = "oidc_state"
+
+	// The refresh token cookie is only sent to the refresh and logout routes.
+	RefreshTokenCookiePath = "/v1/user"
+	OIDCStateCookiePath    = "/v1/user/oidc"
+)
+
+type CookieConfig struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+var cookieConfig = &CookieConfig{
+	Secure:   true,
+	SameSite: http.SameSiteStrictMode,
+}
+
+// NewCookieConfig reads the session cookie attributes from the environment:
+// COOKIE_SECURE (default true), COOKIE_SAME_SITE as strict (default), lax or
+// none, and an optional COOKIE_DOMAIN.
+func NewCookieConfig() error {
+	config := &CookieConfig{
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Domain:   os.Getenv("COOKIE_DOMAIN"),
+	}
+
+	if value := os.Getenv("COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid COOKIE_SECURE %q", value)
+		}
+		config.Secure = secure
+	}
+
+	switch value := os.Getenv("COOKIE_SAME_SITE"); strings.ToLower(value) {
+	case "", "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "lax":
+		config.SameSite = http.SameSiteLaxMode
+	case "none":
+		config.SameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("unsupported COOKIE_SAME_SITE %q", value)
+	}
+
+	if config.SameSite == http.SameSiteNoneMode && !config.Secure {
+		return fmt.Errorf("COOKIE_SAME_SITE none requires COOKIE_SECURE")
+	}
+
+	cookieConfig = config
+	return nil
+}
+
+func NewCookie(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cookieConfig.Domain,
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		Secure:   cookieConfig.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cookieConfig.SameSite,
+	}
+}
+
+func ExpiredCookie(name, path string, httpOnly bool) *http.Cookie {
+	cookie := NewCookie(name, "", path, time.Unix(0, 0), httpOnly)
+	cookie.MaxAge = -1
+	return cookie
+}
+
+func IsSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// VerifyCSRF implements the double-submit check: the X-CSRF-Token header must
+// match the csrf_token cookie, which only our own front-end can read.
+func VerifyCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
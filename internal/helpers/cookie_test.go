@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		want   bool
+	}{
+		{"matching", "token", "token", true},
+		{"mismatched", "token", "other", false},
+		{"missing header", "token", "", false},
+		{"missing cookie", "", "token", false},
+		{"both empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFTokenCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFTokenHeader, tt.header)
+			}
+			if got := VerifyCSRF(r); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCookieConfig(t *testing.T) {
+	t.Cleanup(func() {
+		cookieConfig = &CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}
+	})
+
+	tests := []struct {
+		secure   string
+		sameSite string
+		want     CookieConfig
+		wantErr  bool
+	}{
+		{"", "", CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}, false},
+		{"false", "lax", CookieConfig{Secure: false, SameSite: http.SameSiteLaxMode}, false},
+		{"true", "None", CookieConfig{Secure: true, SameSite: http.SameSiteNoneMode}, false},
+		{"false", "none", CookieConfig{}, true},
+		{"maybe", "", CookieConfig{}, true},
+		{"", "loose", CookieConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("COOKIE_SECURE", tt.secure)
+		t.Setenv("COOKIE_SAME_SITE", tt.sameSite)
+		t.Setenv("COOKIE_DOMAIN", "")
+
+		err := NewCookieConfig()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("COOKIE_SECURE=%q COOKIE_SAME_SITE=%q accepted", tt.secure, tt.sameSite)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("COOKIE_SECURE=%q COOKIE_SAME_SITE=%q: %v", tt.secure, tt.sameSite, err)
+			continue
+		}
+		if *cookieConfig != tt.want {
+			t.Errorf("COOKIE_SECURE=%q COOKIE_SAME_SITE=%q: got %+v, want %+v", tt.secure, tt.sameSite, *cookieConfig, tt.want)
+		}
+	}
+}
+
+func TestNewCookieUsesConfig(t *testing.T) {
+	t.Cleanup(func() {
+		cookieConfig = &CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}
+	})
+	cookieConfig = &CookieConfig{Secure: true, SameSite: http.SameSiteLaxMode, Domain: "example.com"}
+
+	cookie := NewCookie(RefreshTokenCookie, "value", RefreshTokenCookiePath, time.Now().Add(time.Hour), true)
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Domain != "example.com" || cookie.Path != RefreshTokenCookiePath {
+		t.Fatalf("unexpected cookie %+v", cookie)
+	}
+	if cookie.MaxAge <= 0 || cookie.MaxAge > 3600 {
+		t.Fatalf("got MaxAge %d", cookie.MaxAge)
+	}
+
+	expired := ExpiredCookie(RefreshTokenCookie, RefreshTokenCookiePath, true)
+	if expired.MaxAge != -1 || expired.Value != "" {
+		t.Fatalf("unexpected expired cookie %+v", expired)
+	}
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	first, err := EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("the same plaintext encrypted twice gave the same ciphertext")
+	}
+
+	plaintext, err := DecryptString(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "secret" {
+		t.Fatalf("got %q, want %q", plaintext, "secret")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := DecryptString(base64.StdEncoding.EncodeToString(sealed)); err == nil {
+		t.Fatal("tampered ciphertext decrypted")
+	}
+}
+
+func TestEncryptStringRejectsBadKeys(t *testing.T) {
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		t.Setenv("MFA_ENCRYPTION_KEY", key)
+		if _, err := EncryptString("secret"); err == nil {
+			t.Errorf("MFA_ENCRYPTION_KEY %q accepted", key)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 )
 
@@ -53,9 +54,11 @@ func DecodeJSON(r *http.Request, payload interface{}) error {
 }
 
 type ResponseBody struct {
-	Error   string      `json:"error,omitempty"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Message    string      `json:"message"`
+	Warnings   []string    `json:"warnings,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
 func ResponseJSON(w http.ResponseWriter, status int, payload interface{}) error {
@@ -63,3 +66,11 @@ func ResponseJSON(w http.ResponseWriter, status int, payload interface{}) error
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(payload)
 }
+
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
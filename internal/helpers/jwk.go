@@ -0,0 +1,210 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type JWTKeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var jwtKeys *JWTKeySet
+
+// NewJWTKeySet loads the signing configuration from the environment.
+//
+// JWT_SIGNING_METHOD selects HS256 (default), RS256 or EdDSA. HS256 signs with
+// JWT_SECRET. The asymmetric methods read every PEM file in JWT_KEYS_DIR, using
+// the file name without extension as the key ID: all of them verify tokens and
+// the one named by JWT_SIGNING_KEY_ID signs new ones. Rotating a key means
+// adding a new file, switching JWT_SIGNING_KEY_ID, and deleting the old file
+// once the tokens it signed have expired.
+func NewJWTKeySet() error {
+	keySet, err := loadJWTKeySet()
+	if err != nil {
+		return err
+	}
+	jwtKeys = keySet
+	return nil
+}
+
+func loadJWTKeySet() (*JWTKeySet, error) {
+	method := os.Getenv("JWT_SIGNING_METHOD")
+	kid := os.Getenv("JWT_SIGNING_KEY_ID")
+
+	switch method {
+	case "", jwt.SigningMethodHS256.Alg():
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256 signing")
+		}
+		key := &jwtKey{
+			id:        kid,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}
+		return &JWTKeySet{signing: key, keys: map[string]*jwtKey{kid: key}}, nil
+
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		dir := os.Getenv("JWT_KEYS_DIR")
+		if dir == "" || kid == "" {
+			return nil, fmt.Errorf("JWT_KEYS_DIR and JWT_SIGNING_KEY_ID are required for %s signing", method)
+		}
+
+		paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+
+		keySet := &JWTKeySet{keys: map[string]*jwtKey{}}
+		for _, path := range paths {
+			key, err := loadPEMKey(path)
+			if err != nil {
+				return nil, fmt.Errorf("load %s: %w", path, err)
+			}
+			if key.method.Alg() != method {
+				return nil, fmt.Errorf("load %s: key is not a %s key", path, method)
+			}
+			keySet.keys[key.id] = key
+		}
+
+		signing, ok := keySet.keys[kid]
+		if !ok || signing.signKey == nil {
+			return nil, fmt.Errorf("no private key found for JWT_SIGNING_KEY_ID %q", kid)
+		}
+		keySet.signing = signing
+
+		return keySet, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_METHOD %q", method)
+	}
+}
+
+func loadPEMKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &jwtKey{id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := parsed.(type) {
+		case *rsa.PrivateKey:
+			key.method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+		case ed25519.PrivateKey:
+			key.method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+
+	case "RSA PRIVATE KEY":
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := parsed.(type) {
+		case *rsa.PublicKey:
+			key.method, key.verifyKey = jwt.SigningMethodRS256, k
+		case ed25519.PublicKey:
+			key.method, key.verifyKey = jwt.SigningMethodEdDSA, k
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", parsed)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	return key, nil
+}
+
+func JWKS() *JSONWebKeySet {
+	keySet := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	if jwtKeys == nil {
+		return keySet
+	}
+
+	for _, key := range jwtKeys.keys {
+		switch k := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(k),
+			})
+		}
+	}
+
+	sort.Slice(keySet.Keys, func(i, j int) bool {
+		return keySet.Keys[i].Kid < keySet.Keys[j].Kid
+	})
+
+	return keySet
+}
@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+)
+
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadKeys(t *testing.T, method, kid, dir string) {
+	t.Helper()
+	t.Setenv("JWT_SIGNING_METHOD", method)
+	t.Setenv("JWT_SIGNING_KEY_ID", kid)
+	t.Setenv("JWT_KEYS_DIR", dir)
+	if err := NewJWTKeySet(); err != nil {
+		t.Fatalf("NewJWTKeySet: %v", err)
+	}
+}
+
+func TestJWTRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "rsa-1", rsaKey)
+
+	edDir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, edDir, "ed-1", edKey)
+
+	tests := []struct {
+		name   string
+		method string
+		kid    string
+		dir    string
+	}{
+		{"HS256", "HS256", "", ""},
+		{"RS256", "RS256", "rsa-1", dir},
+		{"EdDSA", "EdDSA", "ed-1", edDir},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "test-secret")
+			loadKeys(t, tt.method, tt.kid, tt.dir)
+
+			token, err := CreateJWT(time.Minute, "user-1", "session-1", user_entity.Nurse)
+			if err != nil {
+				t.Fatalf("CreateJWT: %v", err)
+			}
+			credential, err := VerifyJWT(token)
+			if err != nil {
+				t.Fatalf("VerifyJWT: %v", err)
+			}
+			if credential.UserId != "user-1" || credential.SessionID != "session-1" || credential.Role != user_entity.Nurse {
+				t.Fatalf("unexpected credential %+v", credential)
+			}
+		})
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"2024-01", "2024-02"} {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePrivateKey(t, dir, kid, key)
+	}
+
+	loadKeys(t, "RS256", "2024-01", dir)
+	oldToken, err := CreateJWT(time.Minute, "user-1", "", user_entity.IT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadKeys(t, "RS256", "2024-02", dir)
+	if _, err := VerifyJWT(oldToken); err != nil {
+		t.Fatalf("token signed with the previous key: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "2024-01.pem")); err != nil {
+		t.Fatal(err)
+	}
+	loadKeys(t, "RS256", "2024-02", dir)
+	if _, err := VerifyJWT(oldToken); err == nil {
+		t.Fatal("token signed with a removed key was accepted")
+	}
+}
+
+func TestJWKSPublishesPublicKeysOnly(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	loadKeys(t, "HS256", "", "")
+	if keys := JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("HS256 secret published as %+v", keys)
+	}
+
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "rsa-1", rsaKey)
+	loadKeys(t, "RS256", "rsa-1", dir)
+
+	keys := JWKS().Keys
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if key := keys[0]; key.Kid != "rsa-1" || key.Kty != "RSA" || key.Alg != "RS256" || key.N == "" || key.E == "" {
+		t.Fatalf("unexpected key %+v", key)
+	}
+}
+
+func TestVerifyJWTRejectsMFAPendingToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	loadKeys(t, "HS256", "", "")
+
+	token, err := CreateMFAPendingJWT(time.Minute, "user-1", user_entity.IT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyJWT(token); err == nil {
+		t.Fatal("MFA pending token accepted as an access token")
+	}
+	if _, err := VerifyMFAPendingJWT(token); err != nil {
+		t.Fatalf("VerifyMFAPendingJWT: %v", err)
+	}
+}
+
+func TestLoadJWTKeySetRejectsMismatchedKeys(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "ed-1", edKey)
+
+	t.Setenv("JWT_SIGNING_METHOD", "RS256")
+	t.Setenv("JWT_SIGNING_KEY_ID", "ed-1")
+	t.Setenv("JWT_KEYS_DIR", dir)
+	if _, err := loadJWTKeySet(); err == nil {
+		t.Fatal("an EdDSA key was loaded for RS256 signing")
+	}
+}
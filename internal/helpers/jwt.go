@@ -2,51 +2,95 @@ package helpers
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
 	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
 )
 
-var key = []byte(os.Getenv("JWT_SECRET"))
+type TokenType string
+
+const (
+	TokenTypeAccess     TokenType = "access"
+	TokenTypeMFAPending TokenType = "mfa_pending"
+)
 
 type CustomClaims struct {
-	UserId string           `json:"userId"`
-	Role   user_entity.Role `json:"role"`
+	UserId    string           `json:"userId"`
+	Role      user_entity.Role `json:"role"`
+	TokenType TokenType        `json:"tokenType,omitempty"`
+	SessionID string           `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func CreateJWT(ttl time.Duration, userId string, role user_entity.Role) (string, error) {
+func CreateJWT(ttl time.Duration, userId, sessionId string, role user_entity.Role) (string, error) {
+	return createJWT(ttl, userId, sessionId, role, TokenTypeAccess)
+}
+
+// CreateMFAPendingJWT issues a token that only proves the password step of an
+// MFA login, VerifyJWT refuses it so it cannot be used against the API.
+func CreateMFAPendingJWT(ttl time.Duration, userId string, role user_entity.Role) (string, error) {
+	return createJWT(ttl, userId, "", role, TokenTypeMFAPending)
+}
+
+func createJWT(ttl time.Duration, userId, sessionId string, role user_entity.Role, tokenType TokenType) (string, error) {
 	now := time.Now()
 	expiry := now.Add(ttl)
 
 	claims := CustomClaims{
 		userId,
 		role,
+		tokenType,
+		sessionId,
 		jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(expiry),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(key)
+	signing := jwtKeys.signing
+	token := jwt.NewWithClaims(signing.method, claims)
+	if signing.id != "" {
+		token.Header["kid"] = signing.id
+	}
+	return token.SignedString(signing.signKey)
 }
 
 type Credential struct {
-	UserId string           `json:"userId"`
-	Role   user_entity.Role `json:"role"`
+	UserId             string           `json:"userId"`
+	Role               user_entity.Role `json:"role"`
+	TokenID            string           `json:"tokenId"`
+	SessionID          string           `json:"sessionId,omitempty"`
+	IssuedAt           time.Time        `json:"issuedAt"`
+	ExpiresAt          time.Time        `json:"expiresAt"`
+	MustChangePassword bool             `json:"mustChangePassword"`
+	APIKeyID           string           `json:"apiKeyId,omitempty"`
+	Scopes             []string         `json:"scopes,omitempty"`
 }
 
 func VerifyJWT(tokenString string) (*Credential, error) {
+	return verifyJWT(tokenString, TokenTypeAccess)
+}
+
+func VerifyMFAPendingJWT(tokenString string) (*Credential, error) {
+	return verifyJWT(tokenString, TokenTypeMFAPending)
+}
+
+func verifyJWT(tokenString string, tokenType TokenType) (*Credential, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := jwtKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %v", t.Header["kid"])
+		}
+		if t.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
-		return key, nil
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return nil, auth_error.ErrInvalidToken
@@ -65,8 +109,25 @@ func VerifyJWT(tokenString string) (*Credential, error) {
 		return nil, auth_error.ErrUnknownClaims
 	}
 
+	if claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return nil, auth_error.ErrInvalidToken
+	}
+
+	// Access tokens issued before token types existed carry no tokenType.
+	claimedType := claims.TokenType
+	if claimedType == "" {
+		claimedType = TokenTypeAccess
+	}
+	if claimedType != tokenType {
+		return nil, auth_error.ErrInvalidToken
+	}
+
 	return &Credential{
-		UserId: claims.UserId,
-		Role:   claims.Role,
+		UserId:    claims.UserId,
+		Role:      claims.Role,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
 	}, nil
 }
@@ -0,0 +1,18 @@
+package helpers
+
+import (
+	"os"
+
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+)
+
+// NewNIKFormat reads NIK_JSON_FORMAT, number (default) or string, which sets
+// how identity numbers are written in responses.
+func NewNIKFormat() error {
+	format, err := nik.ParseFormat(os.Getenv("NIK_JSON_FORMAT"))
+	if err != nil {
+		return err
+	}
+	nik.SetFormat(format)
+	return nil
+}
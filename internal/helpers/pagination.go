@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultLimit = 5
+	MaxLimit     = 100
+)
+
+type Pagination struct {
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+	Next   *string `json:"next"`
+	Prev   *string `json:"prev"`
+}
+
+// ParsePagination reads the limit and offset query parameters.
+func ParsePagination(query url.Values) (limit, offset int, err error) {
+	limit, offset = DefaultLimit, 0
+
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", MaxLimit)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// NewPagination links to the neighbouring pages with the request's other
+// query parameters kept.
+func NewPagination(r *http.Request, total, limit, offset int) *Pagination {
+	pagination := &Pagination{
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if offset+limit < total {
+		pagination.Next = pageLink(r, limit, offset+limit)
+	}
+	if offset > 0 {
+		pagination.Prev = pageLink(r, limit, max(offset-limit, 0))
+	}
+
+	return pagination
+}
+
+func pageLink(r *http.Request, limit, offset int) *string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = query.Encode()
+	link := u.RequestURI()
+	return &link
+}
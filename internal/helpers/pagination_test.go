@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		valid  bool
+	}{
+		{"", DefaultLimit, 0, true},
+		{"limit=10&offset=20", 10, 20, true},
+		{"limit=1", 1, 0, true},
+		{"limit=100", MaxLimit, 0, true},
+		{"limit=0", 0, 0, false},
+		{"limit=101", 0, 0, false},
+		{"limit=ten", 0, 0, false},
+		{"offset=-1", 0, 0, false},
+		{"offset=first", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			limit, offset, err := ParsePagination(query)
+			if !tt.valid {
+				if err == nil {
+					t.Fatalf("accepted as limit %d offset %d", limit, offset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePagination: %v", err)
+			}
+			if limit != tt.limit || offset != tt.offset {
+				t.Fatalf("got limit %d offset %d, want %d and %d", limit, offset, tt.limit, tt.offset)
+			}
+		})
+	}
+}
+
+func TestNewPagination(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/medical/patient?name=budi&limit=10&offset=10", nil)
+
+	tests := []struct {
+		total, limit, offset int
+		next, prev           string
+	}{
+		{25, 10, 10, "/v1/medical/patient?limit=10&name=budi&offset=20", "/v1/medical/patient?limit=10&name=budi&offset=0"},
+		{20, 10, 10, "", "/v1/medical/patient?limit=10&name=budi&offset=0"},
+		{25, 10, 0, "/v1/medical/patient?limit=10&name=budi&offset=10", ""},
+		{25, 10, 5, "/v1/medical/patient?limit=10&name=budi&offset=15", "/v1/medical/patient?limit=10&name=budi&offset=0"},
+		{0, 10, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		p := NewPagination(r, tt.total, tt.limit, tt.offset)
+		if p.Total != tt.total || p.Limit != tt.limit || p.Offset != tt.offset {
+			t.Errorf("got %+v for total %d limit %d offset %d", p, tt.total, tt.limit, tt.offset)
+		}
+		if got := deref(p.Next); got != tt.next {
+			t.Errorf("offset %d of %d: next = %q, want %q", tt.offset, tt.total, got, tt.next)
+		}
+		if got := deref(p.Prev); got != tt.prev {
+			t.Errorf("offset %d of %d: prev = %q, want %q", tt.offset, tt.total, got, tt.prev)
+		}
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hashedPassword, password string) (bool, error)
+	NeedsRehash(hashedPassword string) bool
+}
+
+// passwordHasher hashes new passwords with the configured algorithm but still
+// verifies hashes produced by the other one, so existing users keep working
+// and get upgraded on their next login.
+type passwordHasher struct {
+	preferred PasswordHasher
+	bcrypt    *BcryptHasher
+	argon2id  *Argon2idHasher
+}
+
+// NewPasswordHasher builds the hasher selected by PASSWORD_HASHER (argon2id by
+// default, or bcrypt). BCRYPT_SALT sets the bcrypt cost and is optional.
+func NewPasswordHasher() (PasswordHasher, error) {
+	cost := bcrypt.DefaultCost
+	if salt := os.Getenv("BCRYPT_SALT"); salt != "" {
+		var err error
+		cost, err = strconv.Atoi(salt)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid BCRYPT_SALT %q", salt)
+		}
+	}
+
+	hasher := &passwordHasher{
+		bcrypt:   NewBcryptHasher(cost),
+		argon2id: NewArgon2idHasher(),
+	}
+
+	switch os.Getenv("PASSWORD_HASHER") {
+	case "", "argon2id":
+		hasher.preferred = hasher.argon2id
+	case "bcrypt":
+		hasher.preferred = hasher.bcrypt
+	default:
+		return nil, fmt.Errorf("unsupported PASSWORD_HASHER %q", os.Getenv("PASSWORD_HASHER"))
+	}
+
+	return hasher, nil
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *passwordHasher) Verify(hashedPassword, password string) (bool, error) {
+	switch {
+	case isArgon2idHash(hashedPassword):
+		return h.argon2id.Verify(hashedPassword, password)
+	case isBcryptHash(hashedPassword):
+		return h.bcrypt.Verify(hashedPassword, password)
+	default:
+		return false, errors.New("unknown password hash format")
+	}
+}
+
+func (h *passwordHasher) NeedsRehash(hashedPassword string) bool {
+	return h.preferred.NeedsRehash(hashedPassword)
+}
@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+//go:embed data/common_passwords.txt
+var commonPasswords string
+
+type PasswordPolicy struct {
+	MinLength        int
+	MinCharClasses   int
+	HistorySize      int
+	blockedPasswords map[string]struct{}
+}
+
+// NewPasswordPolicy reads the policy from the environment:
+// PASSWORD_MIN_LENGTH (default 8), PASSWORD_MIN_CHAR_CLASSES out of lower,
+// upper, digit and symbol (default 3), PASSWORD_HISTORY_SIZE (default 5) and
+// PASSWORD_BLOCKLIST_FILE, an optional list added to the bundled one.
+func NewPasswordPolicy() (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:        8,
+		MinCharClasses:   3,
+		HistorySize:      5,
+		blockedPasswords: map[string]struct{}{},
+	}
+
+	for env, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH":       &policy.MinLength,
+		"PASSWORD_MIN_CHAR_CLASSES": &policy.MinCharClasses,
+		"PASSWORD_HISTORY_SIZE":     &policy.HistorySize,
+	} {
+		if value := os.Getenv(env); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s %q", env, value)
+			}
+			*target = n
+		}
+	}
+
+	if err := policy.loadBlocklist(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if err := policy.loadBlocklist(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+func (p *PasswordPolicy) loadBlocklist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blockedPasswords[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns every rule the password breaks. field names the request field
+// the password came from, personal holds values such as the NIP and name that
+// must not appear in it.
+func (p *PasswordPolicy) Check(field, password string, personal ...string) []user_error.PasswordPolicyViolation {
+	violations := []user_error.PasswordPolicyViolation{}
+	lowered := strings.ToLower(password)
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, user_error.PasswordPolicyViolation{
+			Field:   field,
+			Rule:    "min_length",
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		violations = append(violations, user_error.PasswordPolicyViolation{
+			Field:   field,
+			Rule:    "char_classes",
+			Message: fmt.Sprintf("must mix at least %d of lowercase, uppercase, digits and symbols", p.MinCharClasses),
+		})
+	}
+
+	if containsPersonalInfo(lowered, personal) {
+		violations = append(violations, user_error.PasswordPolicyViolation{
+			Field:   field,
+			Rule:    "personal_info",
+			Message: "must not contain your NIP or name",
+		})
+	}
+
+	if _, ok := p.blockedPasswords[lowered]; ok {
+		violations = append(violations, user_error.PasswordPolicyViolation{
+			Field:   field,
+			Rule:    "common_password",
+			Message: "is too common or has appeared in a data breach",
+		})
+	}
+
+	return violations
+}
+
+func containsPersonalInfo(password string, personal []string) bool {
+	for _, value := range personal {
+		for _, part := range strings.Fields(strings.ToLower(value)) {
+			if len(part) >= 3 && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
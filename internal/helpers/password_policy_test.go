@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func violatedRules(policy *PasswordPolicy, password string, personal ...string) []string {
+	rules := []string{}
+	for _, violation := range policy.Check("password", password, personal...) {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "")
+	t.Setenv("PASSWORD_MIN_CHAR_CLASSES", "")
+	t.Setenv("PASSWORD_HISTORY_SIZE", "")
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", "")
+	policy, err := NewPasswordPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"valid", "Correct-Horse1", []string{}},
+		{"too short", "Ab1-", []string{"min_length"}},
+		{"two classes", "correcthorse1", []string{"char_classes"}},
+		{"three classes", "correcthorse1!", []string{}},
+		{"contains NIP", "Pass-6151200001001", []string{"personal_info"}},
+		{"contains name part", "Suster-Rina-2024", []string{"personal_info"}},
+		{"common password", "Password1", []string{"common_password"}},
+		{"common password in any case", "pASSWORD1", []string{"common_password"}},
+		{"everything wrong", "abc", []string{"min_length", "char_classes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violatedRules(policy, tt.password, "6151200001001", "Rina Wati")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyIgnoresShortNameParts(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, MinCharClasses: 3}
+	if got := violatedRules(policy, "Correct-Horse-Al1", "Al Bi"); len(got) != 0 {
+		t.Fatalf("got %v, want no violations", got)
+	}
+}
+
+func TestNewPasswordPolicyFromEnv(t *testing.T) {
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("# local additions\n\nHalo-Suster-2024\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_MIN_CHAR_CLASSES", "4")
+	t.Setenv("PASSWORD_HISTORY_SIZE", "0")
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", blocklist)
+
+	policy, err := NewPasswordPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.MinLength != 12 || policy.MinCharClasses != 4 || policy.HistorySize != 0 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	if got := violatedRules(policy, "halo-suster-2024"); !reflect.DeepEqual(got, []string{"char_classes", "common_password"}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := violatedRules(policy, "Password1"); !reflect.DeepEqual(got, []string{"min_length", "char_classes", "common_password"}) {
+		t.Fatalf("bundled blocklist not loaded: %v", got)
+	}
+}
+
+func TestNewPasswordPolicyRejectsBadConfig(t *testing.T) {
+	tests := map[string]string{
+		"PASSWORD_MIN_LENGTH":       "-1",
+		"PASSWORD_MIN_CHAR_CLASSES": "three",
+		"PASSWORD_BLOCKLIST_FILE":   filepath.Join(t.TempDir(), "missing.txt"),
+	}
+
+	for env, value := range tests {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			if _, err := NewPasswordPolicy(); err == nil {
+				t.Fatalf("%s=%q accepted", env, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		"argon2id": NewArgon2idHasher(),
+		"bcrypt":   NewBcryptHasher(bcrypt.MinCost),
+	}
+
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hashedPassword, err := hasher.Hash("Correct-Horse1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			isMatch, err := hasher.Verify(hashedPassword, "Correct-Horse1")
+			if err != nil || !isMatch {
+				t.Fatalf("right password: match %v, err %v", isMatch, err)
+			}
+			isMatch, err = hasher.Verify(hashedPassword, "correct-horse1")
+			if err != nil || isMatch {
+				t.Fatalf("wrong password: match %v, err %v", isMatch, err)
+			}
+
+			if hasher.NeedsRehash(hashedPassword) {
+				t.Fatal("a fresh hash needs a rehash")
+			}
+
+			again, err := hasher.Hash("Correct-Horse1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again == hashedPassword {
+				t.Fatal("hashes are not salted")
+			}
+		})
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	weak := &Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hashedPassword, err := weak.Hash("Correct-Horse1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := NewArgon2idHasher()
+	if !hasher.NeedsRehash(hashedPassword) {
+		t.Fatal("hash with weaker parameters does not need a rehash")
+	}
+	isMatch, err := hasher.Verify(hashedPassword, "Correct-Horse1")
+	if err != nil || !isMatch {
+		t.Fatalf("hash with weaker parameters no longer verifies: match %v, err %v", isMatch, err)
+	}
+
+	if !hasher.NeedsRehash("$2a$10$invalid") {
+		t.Fatal("bcrypt hash does not need a rehash to argon2id")
+	}
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	hasher := NewArgon2idHasher()
+	for _, hashedPassword := range []string{
+		"",
+		"$argon2id$v=19$m=19456,t=2,p=1$salt",
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2i$v=19$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=1$!!!$a2V5",
+	} {
+		if _, err := hasher.Verify(hashedPassword, "Correct-Horse1"); err == nil {
+			t.Errorf("%q accepted", hashedPassword)
+		}
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	hashedPassword, err := NewBcryptHasher(bcrypt.MinCost).Hash("Correct-Horse1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(hashedPassword) {
+		t.Fatal("hash with a lower cost does not need a rehash")
+	}
+}
+
+func TestPasswordHasherVerifiesBothFormats(t *testing.T) {
+	t.Setenv("PASSWORD_HASHER", "argon2id")
+	t.Setenv("BCRYPT_SALT", "4")
+	hasher, err := NewPasswordHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := NewBcryptHasher(bcrypt.MinCost).Hash("Correct-Horse1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isMatch, err := hasher.Verify(legacy, "Correct-Horse1")
+	if err != nil || !isMatch {
+		t.Fatalf("bcrypt hash: match %v, err %v", isMatch, err)
+	}
+	if !hasher.NeedsRehash(legacy) {
+		t.Fatal("bcrypt hash is not upgraded to argon2id")
+	}
+
+	hashedPassword, err := hasher.Hash("Correct-Horse1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hashedPassword, "$argon2id$") {
+		t.Fatalf("new hash %q is not argon2id", hashedPassword)
+	}
+
+	if _, err := hasher.Verify("plaintext", "plaintext"); err == nil {
+		t.Fatal("unknown hash format accepted")
+	}
+}
+
+func TestNewPasswordHasherRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		hasher string
+		salt   string
+	}{
+		{"scrypt", ""},
+		{"bcrypt", "abc"},
+		{"bcrypt", "3"},
+		{"bcrypt", "32"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("PASSWORD_HASHER", tt.hasher)
+		t.Setenv("BCRYPT_SALT", tt.salt)
+		if _, err := NewPasswordHasher(); err == nil {
+			t.Errorf("PASSWORD_HASHER=%q BCRYPT_SALT=%q accepted", tt.hasher, tt.salt)
+		}
+	}
+}
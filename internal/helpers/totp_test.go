@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test secret from RFC 6238 appendix B, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d rejected", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("code %s at %d matched step %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	issued := time.Unix(1234567890, 0)
+
+	for _, offset := range []time.Duration{-totpPeriod * time.Second, totpPeriod * time.Second} {
+		if _, ok := ValidateTOTP(rfc6238Secret, "005924", issued.Add(offset)); !ok {
+			t.Errorf("code rejected %v from the step it was issued in", offset)
+		}
+	}
+	for _, offset := range []time.Duration{-2 * totpPeriod * time.Second, 2 * totpPeriod * time.Second} {
+		if _, ok := ValidateTOTP(rfc6238Secret, "005924", issued.Add(offset)); ok {
+			t.Errorf("code accepted %v from the step it was issued in", offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfc6238Secret, "000000"},
+		{"short code", rfc6238Secret, "05924"},
+		{"long code", rfc6238Secret, "0059240"},
+		{"invalid secret", "not base32!", "005924"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok {
+				t.Fatal("code accepted")
+			}
+		})
+	}
+}
+
+func TestValidateTOTPAcceptsLowercaseSecret(t *testing.T) {
+	if _, ok := ValidateTOTP(strings.ToLower(rfc6238Secret), "005924", time.Unix(1234567890, 0)); !ok {
+		t.Fatal("lowercase secret rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret is not unpadded base32: %v", err)
+	}
+	if len(key) != 20 {
+		t.Fatalf("got a %d byte secret, want 20", len(key))
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(TOTPProvisioningURI("Halo Suster", "6151200001001", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Halo Suster:6151200001001" {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Halo Suster",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Unknown key IDs trigger a JWKS refetch at most this often, so tokens signed
+// with garbage kids cannot be used to hammer the IdP.
+const jwksRefreshInterval = time.Minute
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	NIPClaim     string
+	AllowedRoles []user_entity.Role
+	TrustMFA     bool
+}
+
+// NewConfigFromEnv reads OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET,
+// OIDC_REDIRECT_URL, OIDC_SCOPES (default "openid profile"), OIDC_NIP_CLAIM,
+// the ID token claim holding the NIP (default "sub"), OIDC_ALLOWED_ROLES
+// (default "it,nurse") and OIDC_TRUST_IDP_MFA (default false), which lets
+// users with local MFA skip it when signing in through the IdP. It returns nil
+// when OIDC_ISSUER is empty.
+func NewConfigFromEnv() (*Config, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	config := &Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       []string{"openid", "profile"},
+		NIPClaim:     "sub",
+		AllowedRoles: []user_entity.Role{user_entity.IT, user_entity.Nurse},
+	}
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	if claim := os.Getenv("OIDC_NIP_CLAIM"); claim != "" {
+		config.NIPClaim = claim
+	}
+	if roles := os.Getenv("OIDC_ALLOWED_ROLES"); roles != "" {
+		config.AllowedRoles = []user_entity.Role{}
+		for _, role := range strings.Split(roles, ",") {
+			config.AllowedRoles = append(config.AllowedRoles, user_entity.Role(strings.TrimSpace(role)))
+		}
+	}
+	if value := os.Getenv("OIDC_TRUST_IDP_MFA"); value != "" {
+		trustMFA, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OIDC_TRUST_IDP_MFA %q", value)
+		}
+		config.TrustMFA = trustMFA
+	}
+
+	return config, nil
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Provider talks to the IdP. Discovery and signing keys are fetched lazily so
+// the API can start while the IdP is unreachable.
+type Provider struct {
+	Config     *Config
+	HTTPClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewProvider(config *Config, httpClient *http.Client) interfaces.IdentityProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{Config: config, HTTPClient: httpClient}
+}
+
+func (p *Provider) AllowedRoles() []user_entity.Role {
+	return p.Config.AllowedRoles
+}
+
+func (p *Provider) TrustsMFA() bool {
+	return p.Config.TrustMFA
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems the authorization code and returns the identity from the
+// verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*auth_entity.ExternalIdentity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.Config.ClientSecret != "" {
+		form.Set("client_secret", p.Config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	token := &tokenResponse{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(token); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s %s", res.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claimedNonce, _ := claims["nonce"].(string); claimedNonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	subject, _ := claims["sub"].(string)
+	userNIP, err := claimNIP(claims[p.Config.NIPClaim])
+	if err != nil {
+		return nil, fmt.Errorf("claim %q: %w", p.Config.NIPClaim, err)
+	}
+
+	return &auth_entity.ExternalIdentity{Subject: subject, NIP: userNIP}, nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, idToken string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.Config.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	return claims, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	d := &discovery{}
+	wellKnown := strings.TrimSuffix(p.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if d.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match OIDC_ISSUER %q", d.Issuer, p.Config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: missing endpoints")
+	}
+
+	p.discovery = d
+	return d, nil
+}
+
+func (p *Provider) getKey(ctx context.Context, kid string) (interface{}, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	set := &jsonWebKeySet{}
+	if err := p.getJSON(ctx, d.JWKSURI, set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys, err := set.publicKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(target)
+}
+
+func claimNIP(value interface{}) (nip.NIP, error) {
+	switch v := value.(type) {
+	case string:
+		return nip.Parse(v)
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, errors.New("not an integer")
+		}
+		return nip.NIP(v), nil
+	case nil:
+		return 0, errors.New("missing")
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
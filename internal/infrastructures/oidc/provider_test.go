@@ -0,0 +1,406 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is a minimal OpenID provider: it serves discovery and a JWKS, and
+// its token endpoint enforces PKCE before issuing an ID token. Tests tweak
+// the claims or signing key to exercise the provider's checks.
+type mockIdP struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	issuer      string
+	key         *rsa.PrivateKey
+	kid         string
+	signingKey  *rsa.PrivateKey
+	codes       map[string]authorization
+	claims      func(claims jwt.MapClaims)
+	jwksFetches int
+}
+
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{key: key, kid: "idp-1", signingKey: key, codes: map[string]authorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.issuer,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.jwksFetches++
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": idp.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *mockIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	auth, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "code or code_verifier does not match",
+		})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   idp.issuer,
+		"aud":   r.PostForm.Get("client_id"),
+		"sub":   "6151200001001",
+		"nonce": auth.nonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	idToken, err := token.SignedString(idp.signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
+
+// authorize plays the browser leg: it follows the authorization URL the
+// provider built and returns the code the IdP would redirect back with.
+func (idp *mockIdP) authorize(t *testing.T, authorizationURL string) string {
+	t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("response_type") != "code" {
+		t.Fatalf("unexpected authorization request %s", authorizationURL)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := "code-" + query.Get("state")
+	idp.codes[code] = authorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	return code
+}
+
+func newTestProvider(idp *mockIdP) *Provider {
+	config := &Config{
+		Issuer:       idp.server.URL,
+		ClientID:     "halo-suster",
+		RedirectURL:  "https://api.example.com/v1/user/oidc/callback",
+		Scopes:       []string{"openid", "profile"},
+		NIPClaim:     "sub",
+		AllowedRoles: []user_entity.Role{user_entity.IT, user_entity.Nurse},
+	}
+	return NewProvider(config, idp.server.Client()).(*Provider)
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// login runs a full authorization code flow and returns the result of the
+// code exchange.
+func login(t *testing.T, idp *mockIdP, p *Provider, verifier, nonce string) (nip.NIP, error) {
+	t.Helper()
+	ctx := context.Background()
+	authorizationURL, err := p.AuthCodeURL(ctx, "state", "nonce", pkceChallenge("verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	identity, err := p.Exchange(ctx, idp.authorize(t, authorizationURL), verifier, nonce)
+	if err != nil {
+		return 0, err
+	}
+	return identity.NIP, nil
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+
+	userNIP, err := login(t, idp, newTestProvider(idp), "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if userNIP != 6151200001001 {
+		t.Fatalf("got NIP %d", userNIP)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		setup    func(idp *mockIdP)
+	}{
+		{
+			name:     "wrong PKCE verifier",
+			verifier: "other-verifier",
+			nonce:    "nonce",
+		},
+		{
+			name:     "nonce mismatch",
+			verifier: "verifier",
+			nonce:    "other-nonce",
+		},
+		{
+			name:     "signature from another key",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup:    func(idp *mockIdP) { idp.signingKey = otherKey },
+		},
+		{
+			name:     "audience mismatch",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(claims jwt.MapClaims) { claims["aud"] = "another-client" }
+			},
+		},
+		{
+			name:     "issuer mismatch",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }
+			},
+		},
+		{
+			name:     "expired",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }
+			},
+		},
+		{
+			name:     "missing expiry",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(claims jwt.MapClaims) { delete(claims, "exp") }
+			},
+		},
+		{
+			name:     "invalid NIP",
+			verifier: "verifier",
+			nonce:    "nonce",
+			setup: func(idp *mockIdP) {
+				idp.claims = func(claims jwt.MapClaims) { claims["sub"] = "not-a-nip" }
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			if tt.setup != nil {
+				tt.setup(idp)
+			}
+
+			if _, err := login(t, idp, newTestProvider(idp), tt.verifier, tt.nonce); err == nil {
+				t.Fatal("login accepted")
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.issuer = "https://evil.example.com"
+
+	_, err := newTestProvider(idp).AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err == nil {
+		t.Fatal("discovery document for another issuer accepted")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newMockIdP(t)
+
+	authorizationURL, err := newTestProvider(idp).AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Path != "/authorize" {
+		t.Fatalf("got path %q", u.Path)
+	}
+	for key, want := range map[string]string{
+		"client_id":             "halo-suster",
+		"redirect_uri":          "https://api.example.com/v1/user/oidc/callback",
+		"scope":                 "openid profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        "challenge",
+		"code_challenge_method": "S256",
+	} {
+		if got := u.Query().Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestJWKSRefetchIsRateLimited(t *testing.T) {
+	idp := newMockIdP(t)
+	p := newTestProvider(idp)
+
+	if _, err := login(t, idp, p, "verifier", "nonce"); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	// The IdP rotates to a new key: the provider does not refetch the JWKS
+	// until the refresh interval has passed.
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.key, idp.signingKey, idp.kid = newKey, newKey, "idp-2"
+	idp.mu.Unlock()
+
+	if _, err := login(t, idp, p, "verifier", "nonce"); err == nil {
+		t.Fatal("token with an unknown kid accepted")
+	}
+	if idp.jwksFetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", idp.jwksFetches)
+	}
+
+	p.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	if _, err := login(t, idp, p, "verifier", "nonce"); err != nil {
+		t.Fatalf("Exchange after rotation: %v", err)
+	}
+	if idp.jwksFetches != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", idp.jwksFetches)
+	}
+}
+
+func TestExchangeNumericNIPClaim(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = func(claims jwt.MapClaims) { claims["employee_nip"] = 6151200001001 }
+	p := newTestProvider(idp)
+	p.Config.NIPClaim = "employee_nip"
+
+	userNIP, err := login(t, idp, p, "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if userNIP != 6151200001001 {
+		t.Fatalf("got NIP %d", userNIP)
+	}
+}
+
+func TestNewConfigFromEnv(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_CLIENT_ID", "halo-suster")
+	t.Setenv("OIDC_REDIRECT_URL", "https://api.example.com/v1/user/oidc/callback")
+	t.Setenv("OIDC_SCOPES", "")
+	t.Setenv("OIDC_NIP_CLAIM", "")
+	t.Setenv("OIDC_ALLOWED_ROLES", "it")
+	t.Setenv("OIDC_TRUST_IDP_MFA", "")
+
+	config, err := NewConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TrustMFA {
+		t.Fatal("IdP MFA trusted by default")
+	}
+	if len(config.AllowedRoles) != 1 || config.AllowedRoles[0] != user_entity.IT || config.NIPClaim != "sub" {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	t.Setenv("OIDC_TRUST_IDP_MFA", "true")
+	if config, err := NewConfigFromEnv(); err != nil || !config.TrustMFA {
+		t.Fatalf("OIDC_TRUST_IDP_MFA=true: config %+v, err %v", config, err)
+	}
+
+	t.Setenv("OIDC_TRUST_IDP_MFA", "sometimes")
+	if _, err := NewConfigFromEnv(); err == nil {
+		t.Fatal("invalid OIDC_TRUST_IDP_MFA accepted")
+	}
+
+	t.Setenv("OIDC_ISSUER", "")
+	if config, err := NewConfigFromEnv(); err != nil || config != nil {
+		t.Fatalf("OIDC without an issuer: config %+v, err %v", config, err)
+	}
+}
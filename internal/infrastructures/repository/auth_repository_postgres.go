@@ -0,0 +1,559 @@
+package repository_postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	"github.com/danzBraham/halo-suster/internal/domains/repositories"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type AuthRepositoryPostgres struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuthRepositoryPostgres(db *pgxpool.Pool) repositories.AuthRepository {
+	return &AuthRepositoryPostgres{DB: db}
+}
+
+func (r *AuthRepositoryPostgres) CreateRefreshToken(ctx context.Context, token *auth_entity.RefreshToken) error {
+	query := `INSERT INTO
+							refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+							VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.DB.Exec(ctx, query, &token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*auth_entity.RefreshToken, error) {
+	token := &auth_entity.RefreshToken{}
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, created_at
+							FROM refresh_tokens WHERE token_hash = $1`
+	err := r.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, auth_error.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *AuthRepositoryPostgres) RotateRefreshToken(ctx context.Context, oldTokenId string, newToken *auth_entity.RefreshToken) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The revoked_at guard makes the rotation single-use even when the same
+	// token is presented concurrently: only one request can flip it.
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1
+							WHERE id = $2 AND revoked_at IS NULL`
+	tag, err := tx.Exec(ctx, query, &newToken.ID, oldTokenId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return auth_error.ErrRefreshTokenReused
+	}
+
+	query = `INSERT INTO
+						refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+						VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(ctx, query, &newToken.ID, &newToken.UserID, &newToken.FamilyID, &newToken.TokenHash, &newToken.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepositoryPostgres) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	query := "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL"
+	_, err := r.DB.Exec(ctx, query, familyId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	query := "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeAccessToken also purges the denylist of tokens that have expired since,
+// as those are rejected by their expiry alone.
+func (r *AuthRepositoryPostgres) RevokeAccessToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+							ON CONFLICT (jti) DO NOTHING`
+	_, err := r.DB.Exec(ctx, query, tokenId, userId, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM revoked_tokens WHERE expires_at < NOW()"
+	_, err = r.DB.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) IsAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	var isRevoked int
+	query := "SELECT 1 FROM revoked_tokens WHERE jti = $1"
+	err := r.DB.QueryRow(ctx, query, tokenId).Scan(&isRevoked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeUserAccessTokens also purges cutoffs older than AccessTokenTTL, since
+// every token issued before them has expired.
+func (r *AuthRepositoryPostgres) RevokeUserAccessTokens(ctx context.Context, userId string, revokedBefore time.Time) error {
+	query := `INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, $2)
+							ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before`
+	_, err := r.DB.Exec(ctx, query, userId, revokedBefore)
+	if err != nil {
+		return err
+	}
+
+	query = "DELETE FROM user_token_revocations WHERE revoked_before < $1"
+	_, err = r.DB.Exec(ctx, query, time.Now().UTC().Add(-auth_entity.AccessTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) GetUserTokensRevokedBefore(ctx context.Context, userId string) (revokedBefore time.Time, err error) {
+	query := "SELECT revoked_before FROM user_token_revocations WHERE user_id = $1"
+	err = r.DB.QueryRow(ctx, query, userId).Scan(&revokedBefore)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return revokedBefore, nil
+}
+
+func (r *AuthRepositoryPostgres) GetLoginAttempt(ctx context.Context, nip nip.NIP) (*auth_entity.LoginAttempt, error) {
+	attempt := &auth_entity.LoginAttempt{NIP: nip}
+	query := "SELECT failed_count, locked_until FROM login_attempts WHERE nip = $1"
+	err := r.DB.QueryRow(ctx, query, nip).Scan(&attempt.FailedCount, &attempt.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return attempt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// RecordFailedLogin also forgets NIPs that are no longer locked and have not
+// failed for LockoutMaxDuration, so that guessing unknown NIPs cannot grow the
+// table without bound.
+func (r *AuthRepositoryPostgres) RecordFailedLogin(ctx context.Context, nip nip.NIP) (*auth_entity.LoginAttempt, error) {
+	now := time.Now().UTC()
+	query := `DELETE FROM login_attempts
+							WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $2)`
+	_, err := r.DB.Exec(ctx, query, now.Add(-auth_entity.LockoutMaxDuration), now)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &auth_entity.LoginAttempt{NIP: nip}
+	query = `INSERT INTO login_attempts (nip, failed_count, last_failed_at) VALUES ($1, 1, $2)
+							ON CONFLICT (nip) DO UPDATE
+							SET failed_count = login_attempts.failed_count + 1, last_failed_at = EXCLUDED.last_failed_at
+							RETURNING failed_count, locked_until`
+	err = r.DB.QueryRow(ctx, query, nip, now).Scan(&attempt.FailedCount, &attempt.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (r *AuthRepositoryPostgres) LockLogin(ctx context.Context, nip nip.NIP, lockedUntil time.Time) error {
+	query := "UPDATE login_attempts SET locked_until = $1 WHERE nip = $2"
+	_, err := r.DB.Exec(ctx, query, lockedUntil, nip)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) ResetLoginAttempts(ctx context.Context, nip nip.NIP) error {
+	query := "DELETE FROM login_attempts WHERE nip = $1"
+	_, err := r.DB.Exec(ctx, query, nip)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) GetMFA(ctx context.Context, userId string) (*auth_entity.MFA, error) {
+	mfa := &auth_entity.MFA{UserID: userId}
+	query := "SELECT COALESCE(mfa_secret, ''), mfa_enabled, mfa_last_used_step FROM users WHERE id = $1"
+	err := r.DB.QueryRow(ctx, query, userId).Scan(&mfa.Secret, &mfa.Enabled, &mfa.LastUsedStep)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, user_error.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mfa, nil
+}
+
+func (r *AuthRepositoryPostgres) SetMFASecret(ctx context.Context, userId, secret string) error {
+	query := "UPDATE users SET mfa_secret = $1, mfa_enabled = false, mfa_last_used_step = NULL WHERE id = $2"
+	_, err := r.DB.Exec(ctx, query, secret, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) EnableMFA(ctx context.Context, userId string, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE users SET mfa_enabled = true WHERE id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)"
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.Exec(ctx, query, ulid.Make().String(), userId, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepositoryPostgres) DisableMFA(ctx context.Context, userId string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := "UPDATE users SET mfa_secret = NULL, mfa_enabled = false, mfa_last_used_step = NULL WHERE id = $1"
+	_, err = tx.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepositoryPostgres) UseMFAStep(ctx context.Context, userId string, step int64) (bool, error) {
+	query := `UPDATE users SET mfa_last_used_step = $1
+							WHERE id = $2 AND (mfa_last_used_step IS NULL OR mfa_last_used_step < $1)`
+	tag, err := r.DB.Exec(ctx, query, step, userId)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *AuthRepositoryPostgres) UseMFARecoveryCode(ctx context.Context, userId, codeHash string) (bool, error) {
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW()
+							WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SaveSession creates the session or refreshes its activity, client and
+// expiry. Terminated sessions are left untouched and reported as such.
+func (r *AuthRepositoryPostgres) SaveSession(ctx context.Context, session *auth_entity.Session) error {
+	query := `INSERT INTO
+							sessions (id, user_id, user_agent, ip_address, expires_at)
+							VALUES ($1, $2, $3, $4, $5)
+							ON CONFLICT (id) DO UPDATE SET
+								user_agent = EXCLUDED.user_agent,
+								ip_address = EXCLUDED.ip_address,
+								expires_at = EXCLUDED.expires_at,
+								last_active_at = NOW()
+							WHERE sessions.terminated_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query,
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return auth_error.ErrSessionTerminated
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) GetSessions(ctx context.Context, userId string) ([]*auth_entity.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_active_at, expires_at
+							FROM sessions
+							WHERE user_id = $1 AND terminated_at IS NULL AND expires_at > NOW()
+							ORDER BY last_active_at DESC`
+	rows, err := r.DB.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*auth_entity.Session{}
+	for rows.Next() {
+		session := &auth_entity.Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastActiveAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// TouchSession records activity on a session and reports whether it is still
+// active.
+func (r *AuthRepositoryPostgres) TouchSession(ctx context.Context, sessionId string) (bool, error) {
+	query := `UPDATE sessions SET last_active_at = NOW()
+							WHERE id = $1 AND terminated_at IS NULL AND expires_at > NOW()`
+	tag, err := r.DB.Exec(ctx, query, sessionId)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *AuthRepositoryPostgres) TerminateSession(ctx context.Context, userId, sessionId string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE sessions SET terminated_at = NOW()
+							WHERE id = $1 AND user_id = $2 AND terminated_at IS NULL`
+	tag, err := tx.Exec(ctx, query, sessionId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return auth_error.ErrSessionNotFound
+	}
+
+	query = "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL"
+	_, err = tx.Exec(ctx, query, sessionId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepositoryPostgres) TerminateUserSessions(ctx context.Context, userId string) error {
+	query := "UPDATE sessions SET terminated_at = NOW() WHERE user_id = $1 AND terminated_at IS NULL"
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) CreateOIDCLoginState(ctx context.Context, state *auth_entity.OIDCLoginState) error {
+	query := `INSERT INTO
+							oidc_login_states (state_hash, code_verifier, nonce, expires_at)
+							VALUES ($1, $2, $3, $4)`
+	_, err := r.DB.Exec(ctx, query, &state.StateHash, &state.CodeVerifier, &state.Nonce, &state.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginState deletes the state as it reads it so a callback can only
+// be completed once.
+func (r *AuthRepositoryPostgres) ConsumeOIDCLoginState(ctx context.Context, stateHash string) (*auth_entity.OIDCLoginState, error) {
+	state := &auth_entity.OIDCLoginState{}
+	query := `DELETE FROM oidc_login_states WHERE state_hash = $1
+							RETURNING state_hash, code_verifier, nonce, expires_at`
+	err := r.DB.QueryRow(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, auth_error.ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = "DELETE FROM oidc_login_states WHERE expires_at < NOW()"
+	_, err = r.DB.Exec(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (r *AuthRepositoryPostgres) CreateAPIKey(ctx context.Context, apiKey *auth_entity.APIKey) error {
+	query := `INSERT INTO
+							api_keys (id, name, key_prefix, key_hash, scopes, created_by, expires_at)
+							VALUES ($1, $2, $3, $4, $5, $6, $7)
+							RETURNING created_at`
+	err := r.DB.QueryRow(ctx, query,
+		&apiKey.ID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.KeyHash,
+		&apiKey.Scopes,
+		&apiKey.CreatedBy,
+		&apiKey.ExpiresAt,
+	).Scan(&apiKey.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) GetAPIKeys(ctx context.Context) ([]*auth_entity.APIKey, error) {
+	query := `SELECT id, name, key_prefix, scopes, created_by, expires_at, last_used_at, revoked_at, created_at
+							FROM api_keys ORDER BY created_at DESC`
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []*auth_entity.APIKey{}
+	for rows.Next() {
+		apiKey := &auth_entity.APIKey{}
+		err := rows.Scan(
+			&apiKey.ID,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			&apiKey.Scopes,
+			&apiKey.CreatedBy,
+			&apiKey.ExpiresAt,
+			&apiKey.LastUsedAt,
+			&apiKey.RevokedAt,
+			&apiKey.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func (r *AuthRepositoryPostgres) GetAPIKeyByHash(ctx context.Context, keyHash string) (*auth_entity.APIKey, error) {
+	apiKey := &auth_entity.APIKey{}
+	query := `SELECT id, name, key_prefix, key_hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at
+							FROM api_keys WHERE key_hash = $1`
+	err := r.DB.QueryRow(ctx, query, keyHash).Scan(
+		&apiKey.ID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.KeyHash,
+		&apiKey.Scopes,
+		&apiKey.CreatedBy,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.RevokedAt,
+		&apiKey.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, auth_error.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+func (r *AuthRepositoryPostgres) RevokeAPIKey(ctx context.Context, apiKeyId string) error {
+	query := "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1"
+	tag, err := r.DB.Exec(ctx, query, apiKeyId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return auth_error.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *AuthRepositoryPostgres) TouchAPIKey(ctx context.Context, apiKeyId string, usedAt time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2
+							WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`
+	_, err := r.DB.Exec(ctx, query, apiKeyId, usedAt, usedAt.Add(-auth_entity.APIKeyLastUsedInterval))
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository_postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
+
+func TestRevokeAccessTokenPurgesExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	userId := createTestITUser(t, &UserRepositoryPostgres{DB: db}, 1)
+	r := &AuthRepositoryPostgres{DB: db}
+	now := time.Now().UTC()
+
+	if err := r.RevokeAccessToken(ctx, "expired", userId, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeAccessToken(ctx, "current", userId, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if isRevoked, err := r.IsAccessTokenRevoked(ctx, "expired"); err != nil || isRevoked {
+		t.Fatalf("expired token still listed: %v, %v", isRevoked, err)
+	}
+	if isRevoked, err := r.IsAccessTokenRevoked(ctx, "current"); err != nil || !isRevoked {
+		t.Fatalf("current token not listed: %v, %v", isRevoked, err)
+	}
+}
+
+func TestRevokeUserAccessTokensPurgesOldCutoffs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	users := &UserRepositoryPostgres{DB: db}
+	stale, current := createTestITUser(t, users, 1), createTestITUser(t, users, 2)
+	r := &AuthRepositoryPostgres{DB: db}
+	now := time.Now().UTC()
+
+	if err := r.RevokeUserAccessTokens(ctx, stale, now.Add(-auth_entity.AccessTokenTTL-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeUserAccessTokens(ctx, current, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if revokedBefore, err := r.GetUserTokensRevokedBefore(ctx, stale); err != nil || !revokedBefore.IsZero() {
+		t.Fatalf("stale cutoff kept: %v, %v", revokedBefore, err)
+	}
+	if revokedBefore, err := r.GetUserTokensRevokedBefore(ctx, current); err != nil || revokedBefore.IsZero() {
+		t.Fatalf("current cutoff lost: %v, %v", revokedBefore, err)
+	}
+}
+
+func TestRecordFailedLoginPurgesStaleAttempts(t *testing.T) {
+	ctx := context.Background()
+	r := &AuthRepositoryPostgres{DB: newTestDB(t)}
+	stale, locked, fresh := mustNIP(t, nip.PrefixNurse, 1), mustNIP(t, nip.PrefixNurse, 2), mustNIP(t, nip.PrefixNurse, 3)
+
+	setup := `INSERT INTO login_attempts (nip, failed_count, locked_until, last_failed_at) VALUES
+							($1, 3, NULL, NOW() - INTERVAL '2 hours'),
+							($2, 9, NOW() + INTERVAL '30 minutes', NOW() - INTERVAL '2 hours')`
+	if _, err := r.DB.Exec(ctx, setup, stale, locked); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.RecordFailedLogin(ctx, fresh); err != nil {
+		t.Fatalf("RecordFailedLogin: %v", err)
+	}
+
+	if attempt, err := r.GetLoginAttempt(ctx, stale); err != nil || attempt.FailedCount != 0 {
+		t.Fatalf("stale attempts kept: %+v, %v", attempt, err)
+	}
+	if attempt, err := r.GetLoginAttempt(ctx, locked); err != nil || attempt.FailedCount != 9 {
+		t.Fatalf("locked NIP forgotten: %+v, %v", attempt, err)
+	}
+	if attempt, err := r.GetLoginAttempt(ctx, fresh); err != nil || attempt.FailedCount != 1 {
+		t.Fatalf("new failure not recorded: %+v, %v", attempt, err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
 	"github.com/danzBraham/halo-suster/internal/domains/repositories"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
@@ -22,10 +24,10 @@ func NewMedicalRepositoryPostgres(db *pgxpool.Pool) repositories.MedicalReposito
 	return &MedicalRepositoryPostgres{DB: db}
 }
 
-func (r *MedicalRepositoryPostgres) VerifyIdentityNumber(ctx context.Context, identityNumber int) (bool, error) {
+func (r *MedicalRepositoryPostgres) VerifyIdentityNumber(ctx context.Context, identityNumber nik.NIK) (bool, error) {
 	var isIdentityNumberExists int
-	query := "SELECT 1 FROM patients WHERE identity_number = $1"
-	err := r.DB.QueryRow(ctx, query, strconv.Itoa(identityNumber)).Scan(&isIdentityNumberExists)
+	query := "SELECT 1 FROM patients WHERE identity_number = $1 AND is_deleted = false"
+	err := r.DB.QueryRow(ctx, query, identityNumber).Scan(&isIdentityNumberExists)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return false, nil
 	}
@@ -38,17 +40,25 @@ func (r *MedicalRepositoryPostgres) VerifyIdentityNumber(ctx context.Context, id
 func (r *MedicalRepositoryPostgres) CreatePatient(ctx context.Context, payload *medical_entity.AddMedicalPatient) error {
 	id := ulid.Make().String()
 	query := `INSERT INTO 
-							patients (id, identity_number, phone_number, name, birth_date, gender, card_image_url)
-							VALUES ($1, $2, $3, $4, $5, $6, $7)`
+							patients (id, identity_number, phone_number, name, birth_date, gender, card_image_url, created_by, created_by_api_key)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))`
 	_, err := r.DB.Exec(ctx, query,
 		id,
-		strconv.Itoa(payload.IdentityNumber),
+		payload.IdentityNumber,
 		&payload.PhoneNumber,
 		&payload.Name,
 		&payload.BirthDate,
 		&payload.Gender,
-		&payload.CardImageURL)
-
+		&payload.CardImageURL,
+		&payload.CreatedBy,
+		&payload.APIKeyID)
+
+	// identity_number stays unique across deleted patients because
+	// medical_records references it, so a deleted patient has to be restored
+	// rather than registered again.
+	if isUniqueViolation(err) {
+		return medical_error.ErrPatientIsDeleted
+	}
 	if err != nil {
 		return err
 	}
@@ -56,84 +66,227 @@ func (r *MedicalRepositoryPostgres) CreatePatient(ctx context.Context, payload *
 	return nil
 }
 
-func (r *MedicalRepositoryPostgres) GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, error) {
-	query := `SELECT identity_number, phone_number, name, birth_date, gender, created_at 
+var patientSortColumns = map[string]string{
+	"createdAt": "created_at",
+	"name":      "name",
+	"birthDate": "birth_date",
+}
+
+// escapeLike makes s match literally in a LIKE pattern with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *MedicalRepositoryPostgres) GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, int, error) {
+	query := `SELECT identity_number, phone_number, name, birth_date, gender, created_at, COUNT(*) OVER()
 							FROM patients WHERE is_deleted = false`
 	args := []interface{}{}
 	argID := 1
 
 	if params.IdentityNumber != "" {
-		query += ` AND identity_number LIKE $` + strconv.Itoa(argID)
-		args = append(args, "%"+params.IdentityNumber+"%")
+		query += ` AND identity_number LIKE $` + strconv.Itoa(argID) + ` ESCAPE '\'`
+		args = append(args, "%"+escapeLike(params.IdentityNumber)+"%")
+		argID++
+	}
+
+	if params.ProvinceCode != "" {
+		query += ` AND LEFT(identity_number, 2) = $` + strconv.Itoa(argID)
+		args = append(args, params.ProvinceCode)
 		argID++
 	}
 
 	if params.PhoneNumber != "" {
-		query += ` AND phone_number LIKE $` + strconv.Itoa(argID)
-		args = append(args, "%"+strings.TrimPrefix(params.PhoneNumber, "+")+"%")
+		query += ` AND phone_number LIKE $` + strconv.Itoa(argID) + ` ESCAPE '\'`
+		args = append(args, "%"+escapeLike(strings.TrimPrefix(params.PhoneNumber, "+"))+"%")
 		argID++
 	}
 
+	orderBy := "created_at DESC"
+
+	// Names match on a substring or on trigram word similarity, so typos
+	// still find the patient. Results are ranked by similarity unless a sort
+	// is requested.
 	if params.Name != "" {
-		query += ` AND LOWER(name) LIKE $` + strconv.Itoa(argID)
-		args = append(args, "%"+params.Name+"%")
+		query += ` AND (name ILIKE $` + strconv.Itoa(argID) + ` ESCAPE '\' OR $` + strconv.Itoa(argID+1) + ` <% name)`
+		args = append(args, "%"+escapeLike(params.Name)+"%", params.Name)
+		orderBy = "word_similarity($" + strconv.Itoa(argID+1) + ", name) DESC, created_at DESC"
+		argID += 2
+	}
+
+	if params.Gender != "" {
+		query += ` AND gender = $` + strconv.Itoa(argID)
+		args = append(args, params.Gender)
 		argID++
 	}
 
-	switch params.CreatedAt {
-	case "asc":
-		query += " ORDER BY created_at ASC"
-	case "desc":
-		query += " ORDER BY created_at DESC"
+	if params.BirthDateFrom != nil {
+		query += ` AND birth_date::date >= $` + strconv.Itoa(argID) + `::date`
+		args = append(args, *params.BirthDateFrom)
+		argID++
 	}
 
+	if params.BirthDateTo != nil {
+		query += ` AND birth_date::date <= $` + strconv.Itoa(argID) + `::date`
+		args = append(args, *params.BirthDateTo)
+		argID++
+	}
+
+	// Age is counted in whole years as of today.
+	if params.MinAge != nil {
+		query += ` AND birth_date::date <= CURRENT_DATE - make_interval(years => $` + strconv.Itoa(argID) + `)`
+		args = append(args, *params.MinAge)
+		argID++
+	}
+
+	if params.MaxAge != nil {
+		query += ` AND birth_date::date > CURRENT_DATE - make_interval(years => $` + strconv.Itoa(argID) + ` + 1)`
+		args = append(args, *params.MaxAge)
+		argID++
+	}
+
+	if column, ok := patientSortColumns[params.SortBy]; ok {
+		direction := "ASC"
+		if params.Order == "desc" {
+			direction = "DESC"
+		}
+		orderBy = column + " " + direction + ", created_at DESC"
+	}
+
+	filterQuery, filterArgs := query, args
+	query += " ORDER BY " + orderBy
+
 	query += " LIMIT $" + strconv.Itoa(argID) + " OFFSET $" + strconv.Itoa(argID+1)
 	args = append(args, params.Limit, params.Offset)
 
 	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
+	var total int
 	medicalPatients := []*medical_entity.MedicalPatient{}
 	for rows.Next() {
-		var identityNumber string
 		var medicalPatient medical_entity.MedicalPatient
 		err := rows.Scan(
-			&identityNumber,
+			&medicalPatient.IdentityNumber,
 			&medicalPatient.PhoneNumber,
 			&medicalPatient.Name,
 			&medicalPatient.BirthDate,
 			&medicalPatient.Gender,
 			&medicalPatient.CreatedAt,
+			&total,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		identityNumberInt, err := strconv.Atoi(identityNumber)
+		medicalPatient.Province = medicalPatient.IdentityNumber.Province()
+		medicalPatients = append(medicalPatients, &medicalPatient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(medicalPatients) == 0 && params.Offset > 0 {
+		total, err = countFiltered(ctx, r.DB, filterQuery, filterArgs)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		medicalPatient.IdentityNumber = identityNumberInt
-		medicalPatients = append(medicalPatients, &medicalPatient)
 	}
 
-	return medicalPatients, nil
+	return medicalPatients, total, nil
+}
+
+func (r *MedicalRepositoryPostgres) GetMedicalPatient(ctx context.Context, identityNumber nik.NIK) (*medical_entity.MedicalPatientDetail, error) {
+	patient := &medical_entity.MedicalPatientDetail{IdentityNumber: identityNumber}
+	query := `SELECT phone_number, name, birth_date, gender, COALESCE(card_image_url, ''), created_at, updated_at
+							FROM patients WHERE identity_number = $1 AND is_deleted = false`
+	err := r.DB.QueryRow(ctx, query, identityNumber).Scan(
+		&patient.PhoneNumber,
+		&patient.Name,
+		&patient.BirthDate,
+		&patient.Gender,
+		&patient.CardImageURL,
+		&patient.CreatedAt,
+		&patient.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, medical_error.ErrPatientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	patient.Province = identityNumber.Province()
+	return patient, nil
+}
+
+// UpdatePatient leaves columns whose field is nil untouched.
+func (r *MedicalRepositoryPostgres) UpdatePatient(ctx context.Context, payload *medical_entity.PatchMedicalPatient) error {
+	query := `UPDATE patients SET
+							phone_number = COALESCE($1, phone_number),
+							name = COALESCE($2, name),
+							birth_date = COALESCE($3::timestamp, birth_date),
+							gender = COALESCE($4::genders, gender),
+							card_image_url = COALESCE($5, card_image_url),
+							updated_at = NOW()
+						WHERE identity_number = $6 AND is_deleted = false`
+	tag, err := r.DB.Exec(ctx, query,
+		payload.PhoneNumber,
+		payload.Name,
+		payload.BirthDate,
+		payload.Gender,
+		payload.CardImageURL,
+		payload.IdentityNumber,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return medical_error.ErrPatientNotFound
+	}
+	return nil
+}
+
+func (r *MedicalRepositoryPostgres) DeletePatient(ctx context.Context, identityNumber nik.NIK) error {
+	query := "UPDATE patients SET is_deleted = true, updated_at = NOW() WHERE identity_number = $1 AND is_deleted = false"
+	tag, err := r.DB.Exec(ctx, query, identityNumber)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return medical_error.ErrPatientNotFound
+	}
+	return nil
+}
+
+// RestorePatient undeletes the patient with identityNumber. It fails with
+// ErrPatientNotFound when there is no deleted patient to restore.
+func (r *MedicalRepositoryPostgres) RestorePatient(ctx context.Context, identityNumber nik.NIK) error {
+	query := "UPDATE patients SET is_deleted = false, updated_at = NOW() WHERE identity_number = $1 AND is_deleted = true"
+	tag, err := r.DB.Exec(ctx, query, identityNumber)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return medical_error.ErrPatientNotFound
+	}
+	return nil
 }
 
 func (r *MedicalRepositoryPostgres) CreateMedicalRecord(ctx context.Context, payload *medical_entity.AddMedicalRecord) error {
 	id := ulid.Make().String()
 	log.Println(payload.UserID)
 	query := `INSERT INTO 
-							medical_records (id, symptoms, medications, patient_identity_number, created_by)
-							VALUES ($1, $2, $3, $4, $5)`
+							medical_records (id, symptoms, medications, diagnosis, patient_identity_number, created_by, created_by_api_key)
+							VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''))`
 	_, err := r.DB.Exec(ctx, query,
 		id,
 		&payload.Symptoms,
 		&payload.Medications,
-		strconv.Itoa(payload.IdentityNumber),
+		&payload.Diagnosis,
+		payload.IdentityNumber,
 		&payload.UserID,
+		&payload.APIKeyID,
 	)
 
 	if err != nil {
@@ -143,15 +296,15 @@ func (r *MedicalRepositoryPostgres) CreateMedicalRecord(ctx context.Context, pay
 	return nil
 }
 
-func (r *MedicalRepositoryPostgres) GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, error) {
+func (r *MedicalRepositoryPostgres) GetMedicalRecords(ctx context.Context, params *medical_entity.MedicalRecordParams) ([]*medical_entity.MedicalRecord, int, error) {
 	query := `SELECT
 							p.identity_number, p.phone_number, p.name, p.birth_date, p.gender, p.card_image_url,
-							m.symptoms, m.medications, m.created_at,
-							u.nip, u.name, u.id
+							m.symptoms, m.medications, COALESCE(m.diagnosis, ''), m.created_at,
+							u.nip, u.name, u.id, m.created_by_api_key, COUNT(*) OVER()
 						FROM medical_records m
 						INNER JOIN patients p ON m.patient_identity_number = p.identity_number
 						INNER JOIN users u ON m.created_by = u.id
-						WHERE m.is_deleted = false`
+						WHERE m.is_deleted = false AND p.is_deleted = false`
 	args := []interface{}{}
 	argID := 1
 
@@ -173,6 +326,8 @@ func (r *MedicalRepositoryPostgres) GetMedicalRecords(ctx context.Context, param
 		argID++
 	}
 
+	filterQuery, filterArgs := query, args
+
 	switch params.CreatedAt {
 	case "asc":
 		query += " ORDER BY created_at ASC"
@@ -185,45 +340,42 @@ func (r *MedicalRepositoryPostgres) GetMedicalRecords(ctx context.Context, param
 
 	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
+	var total int
 	medicalRecords := []*medical_entity.MedicalRecord{}
 	for rows.Next() {
-		var identityNumber string
-		var nipStr string
 		var identityDetail medical_entity.IdentityDetail
 		var medicalRecord medical_entity.MedicalRecord
 		var createdByDetail medical_entity.CreatedByDetail
 
 		err := rows.Scan(
-			&identityNumber, &identityDetail.PhoneNumber, &identityDetail.Name, &identityDetail.BirthDate, &identityDetail.Gender, &identityDetail.CardImageURL,
-			&medicalRecord.Symptoms, &medicalRecord.Medications, &medicalRecord.CreatedAt,
-			&nipStr, &createdByDetail.Name, &createdByDetail.UserID,
+			&identityDetail.IdentityNumber, &identityDetail.PhoneNumber, &identityDetail.Name, &identityDetail.BirthDate, &identityDetail.Gender, &identityDetail.CardImageURL,
+			&medicalRecord.Symptoms, &medicalRecord.Medications, &medicalRecord.Diagnosis, &medicalRecord.CreatedAt,
+			&createdByDetail.NIP, &createdByDetail.Name, &createdByDetail.UserID, &createdByDetail.APIKeyID,
+			&total,
 		)
 		if err != nil {
-			return nil, err
-		}
-
-		identityNumberInt, err := strconv.Atoi(identityNumber)
-		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
-		nip, err := strconv.Atoi(nipStr)
-		if err != nil {
-			return nil, err
-		}
-
-		identityDetail.IdentityNumber = identityNumberInt
-		createdByDetail.NIP = nip
-
 		medicalRecord.IdentityDetail = identityDetail
 		medicalRecord.CreatedByDetail = createdByDetail
 
 		medicalRecords = append(medicalRecords, &medicalRecord)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(medicalRecords) == 0 && params.Offset > 0 {
+		total, err = countFiltered(ctx, r.DB, filterQuery, filterArgs)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
 
-	return medicalRecords, nil
+	return medicalRecords, total, nil
 }
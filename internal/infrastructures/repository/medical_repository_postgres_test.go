@@ -0,0 +1,155 @@
+package repository_postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"budi":    "budi",
+		"100%":    `100\%`,
+		"a_b":     `a\_b`,
+		`c:\d`:    `c:\\d`,
+		`%_\`:     `\%\_\\`,
+		"+62812%": `+62812\%`,
+	}
+
+	for input, want := range tests {
+		if got := escapeLike(input); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func createTestPatient(t *testing.T, r *MedicalRepositoryPostgres, createdBy string, identityNumber nik.NIK, name, phoneNumber string) {
+	t.Helper()
+	err := r.CreatePatient(context.Background(), &medical_entity.AddMedicalPatient{
+		IdentityNumber: identityNumber,
+		PhoneNumber:    phoneNumber,
+		Name:           name,
+		BirthDate:      "1990-08-15T00:00:00Z",
+		Gender:         medical_entity.Male,
+		CardImageURL:   "https://example.com/card.png",
+		CreatedBy:      createdBy,
+	})
+	if err != nil {
+		t.Fatalf("CreatePatient: %v", err)
+	}
+}
+
+func TestGetMedicalPatientsFilters(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	userId := createTestITUser(t, &UserRepositoryPostgres{DB: db}, 1)
+	r := &MedicalRepositoryPostgres{DB: db}
+
+	createTestPatient(t, r, userId, "3201011508900001", "Budi Santoso", "+6281234567890")
+	createTestPatient(t, r, userId, "3301011508900002", "Siti Aminah", "+6281298765432")
+	createTestPatient(t, r, userId, "3501011508900003", "Agus Salim", "+6285711112222")
+
+	tests := []struct {
+		name   string
+		params medical_entity.MedicalPatientParams
+		want   []nik.NIK
+	}{
+		{"all", medical_entity.MedicalPatientParams{}, []nik.NIK{"3501011508900003", "3301011508900002", "3201011508900001"}},
+		{"identity number", medical_entity.MedicalPatientParams{IdentityNumber: "33010115"}, []nik.NIK{"3301011508900002"}},
+		{"identity number wildcard", medical_entity.MedicalPatientParams{IdentityNumber: "32%1"}, nil},
+		{"phone number", medical_entity.MedicalPatientParams{PhoneNumber: "+62857"}, []nik.NIK{"3501011508900003"}},
+		{"phone number wildcard", medical_entity.MedicalPatientParams{PhoneNumber: "62_12"}, nil},
+		{"province", medical_entity.MedicalPatientParams{ProvinceCode: "32"}, []nik.NIK{"3201011508900001"}},
+		{"name substring", medical_entity.MedicalPatientParams{Name: "aminah"}, []nik.NIK{"3301011508900002"}},
+		{"name typo", medical_entity.MedicalPatientParams{Name: "Santosa"}, []nik.NIK{"3201011508900001"}},
+		{"name wildcard", medical_entity.MedicalPatientParams{Name: "%"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params.Limit = 10
+			patients, total, err := r.GetMedicalPatients(ctx, &params)
+			if err != nil {
+				t.Fatalf("GetMedicalPatients: %v", err)
+			}
+			if len(patients) != len(tt.want) || total != len(tt.want) {
+				t.Fatalf("got %d patients of %d, want %v", len(patients), total, tt.want)
+			}
+			for i, patient := range patients {
+				if patient.IdentityNumber != tt.want[i] {
+					t.Errorf("patient %d: got %s, want %s", i, patient.IdentityNumber, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetMedicalPatientsPagination(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	userId := createTestITUser(t, &UserRepositoryPostgres{DB: db}, 1)
+	r := &MedicalRepositoryPostgres{DB: db}
+	for _, identityNumber := range []nik.NIK{"3201011508900001", "3201011508900002", "3201011508900003"} {
+		createTestPatient(t, r, userId, identityNumber, "Budi Santoso", "+6281234567890")
+	}
+
+	tests := []struct {
+		offset, rows int
+	}{
+		{0, 2},
+		{2, 1},
+		{4, 0},
+	}
+	for _, tt := range tests {
+		patients, total, err := r.GetMedicalPatients(ctx, &medical_entity.MedicalPatientParams{Limit: 2, Offset: tt.offset})
+		if err != nil {
+			t.Fatalf("GetMedicalPatients: %v", err)
+		}
+		if len(patients) != tt.rows || total != 3 {
+			t.Errorf("offset %d: got %d rows of %d, want %d of 3", tt.offset, len(patients), total, tt.rows)
+		}
+	}
+}
+
+func TestDeletedPatientRestore(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	userId := createTestITUser(t, &UserRepositoryPostgres{DB: db}, 1)
+	r := &MedicalRepositoryPostgres{DB: db}
+	const identityNumber nik.NIK = "3201011508900001"
+
+	createTestPatient(t, r, userId, identityNumber, "Budi Santoso", "+6281234567890")
+	if err := r.DeletePatient(ctx, identityNumber); err != nil {
+		t.Fatalf("DeletePatient: %v", err)
+	}
+
+	if isExists, err := r.VerifyIdentityNumber(ctx, identityNumber); err != nil || isExists {
+		t.Fatalf("deleted patient counted as existing: %v, %v", isExists, err)
+	}
+	err := r.CreatePatient(ctx, &medical_entity.AddMedicalPatient{
+		IdentityNumber: identityNumber,
+		PhoneNumber:    "+6281234567890",
+		Name:           "Budi Santoso",
+		BirthDate:      "1990-08-15T00:00:00Z",
+		Gender:         medical_entity.Male,
+		CreatedBy:      userId,
+	})
+	if !errors.Is(err, medical_error.ErrPatientIsDeleted) {
+		t.Fatalf("CreatePatient over a deleted patient: got %v, want ErrPatientIsDeleted", err)
+	}
+
+	if err := r.RestorePatient(ctx, identityNumber); err != nil {
+		t.Fatalf("RestorePatient: %v", err)
+	}
+	if err := r.RestorePatient(ctx, identityNumber); !errors.Is(err, medical_error.ErrPatientNotFound) {
+		t.Fatalf("RestorePatient of an active patient: got %v, want ErrPatientNotFound", err)
+	}
+	if _, err := r.GetMedicalPatient(ctx, identityNumber); err != nil {
+		t.Fatalf("GetMedicalPatient after restore: %v", err)
+	}
+}
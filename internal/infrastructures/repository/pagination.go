@@ -0,0 +1,16 @@
+package repository_postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// countFiltered counts the rows a list query matches. List queries read the
+// total from COUNT(*) OVER(), which has no row to ride on once the offset is
+// past the end.
+func countFiltered(ctx context.Context, db *pgxpool.Pool, query string, args []interface{}) (int, error) {
+	var total int
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM ("+query+") AS filtered", args...).Scan(&total)
+	return total, err
+}
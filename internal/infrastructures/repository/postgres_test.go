@@ -0,0 +1,121 @@
+package repository_postgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The repository tests run against the database in TEST_DATABASE_URL and are
+// skipped without it. Every test empties all tables first, so never point it
+// at a database whose data matters.
+
+var (
+	testDB     *pgxpool.Pool
+	testDBErr  error
+	testDBOnce sync.Once
+)
+
+func newTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	connString := os.Getenv("TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testDBOnce.Do(func() {
+		testDB, testDBErr = connectTestDB(connString)
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+
+	ctx := context.Background()
+	_, err := testDB.Exec(ctx, `DO $$
+		BEGIN
+			EXECUTE (SELECT 'TRUNCATE ' || string_agg(quote_ident(tablename), ', ') || ' CASCADE'
+								FROM pg_tables WHERE schemaname = current_schema());
+		END $$`)
+	if err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	return testDB
+}
+
+// connectTestDB connects in UTC, matching the times the repositories write,
+// and applies every up migration. They are all safe to run again.
+func connectTestDB(connString string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	config.ConnConfig.RuntimeParams["timezone"] = "UTC"
+
+	ctx := context.Background()
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := filepath.Glob("../../../db/migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(migrations)
+	for _, migration := range migrations {
+		sql, err := os.ReadFile(migration)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Exec(ctx, string(sql))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func mustNIP(t *testing.T, prefix nip.Prefix, sequence int) nip.NIP {
+	t.Helper()
+	n, err := nip.New(prefix, nip.Male, 2024, 3, sequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func createTestITUser(t *testing.T, r *UserRepositoryPostgres, sequence int) string {
+	t.Helper()
+	userId, err := r.CreateITUser(context.Background(), &user_entity.RegisterITUser{
+		NIP:      mustNIP(t, nip.PrefixIT, sequence),
+		Name:     "Admin User",
+		Password: "hashed",
+	})
+	if err != nil {
+		t.Fatalf("CreateITUser: %v", err)
+	}
+	return userId
+}
+
+func createTestNurse(t *testing.T, r *UserRepositoryPostgres, sequence int) string {
+	t.Helper()
+	userId, err := r.CreateStaffUser(context.Background(), user_entity.Nurse, &user_entity.RegisterStaffUser{
+		NIP:          mustNIP(t, nip.PrefixNurse, sequence),
+		Name:         "Nurse User",
+		CardImageURL: "https://example.com/card.png",
+	})
+	if err != nil {
+		t.Fatalf("CreateStaffUser: %v", err)
+	}
+	return userId
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
 	"github.com/danzBraham/halo-suster/internal/domains/repositories"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
 	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 )
@@ -22,10 +25,10 @@ func NewUserRepositoryPostgres(db *pgxpool.Pool) repositories.UserRepository {
 	return &UserRepositoryPostgres{DB: db}
 }
 
-func (r *UserRepositoryPostgres) VerifyNIP(ctx context.Context, nip int) (bool, error) {
+func (r *UserRepositoryPostgres) VerifyNIP(ctx context.Context, nip nip.NIP) (bool, error) {
 	var isNIPExists string
-	query := "SELECT 1 FROM users WHERE nip = $1"
-	err := r.DB.QueryRow(ctx, query, strconv.Itoa(nip)).Scan(&isNIPExists)
+	query := "SELECT 1 FROM users WHERE nip = $1 AND is_deleted = false"
+	err := r.DB.QueryRow(ctx, query, nip).Scan(&isNIPExists)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return false, nil
 	}
@@ -35,68 +38,157 @@ func (r *UserRepositoryPostgres) VerifyNIP(ctx context.Context, nip int) (bool,
 	return true, nil
 }
 
+// AllocateNIP hands out the next sequence for the prefix, gender and month.
+// The sequence row stays locked until commit, so concurrent registrations get
+// distinct numbers. Sequences already taken by hand-entered or deleted users
+// are skipped.
+func (r *UserRepositoryPostgres) AllocateNIP(ctx context.Context, prefix nip.Prefix, gender nip.Gender, year int, month time.Month) (nip.NIP, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO nip_sequences (prefix, gender, year, month, last_sequence) VALUES ($1, $2, $3, $4, 1)
+							ON CONFLICT (prefix, gender, year, month) DO UPDATE
+							SET last_sequence = nip_sequences.last_sequence + 1
+							RETURNING last_sequence`
+	for {
+		var sequence int
+		err = tx.QueryRow(ctx, query, int(prefix), int(gender), year, int(month)).Scan(&sequence)
+		if err != nil {
+			return 0, err
+		}
+		if sequence > nip.MaxSequence {
+			return 0, user_error.ErrNIPSequenceExhausted
+		}
+
+		candidate, err := nip.New(prefix, gender, year, month, sequence)
+		if err != nil {
+			return 0, err
+		}
+
+		var isTaken bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE nip = $1)", candidate).Scan(&isTaken)
+		if err != nil {
+			return 0, err
+		}
+		if !isTaken {
+			return candidate, tx.Commit(ctx)
+		}
+	}
+}
+
 func (r *UserRepositoryPostgres) CreateITUser(ctx context.Context, payload *user_entity.RegisterITUser) (userId string, err error) {
 	userId = ulid.Make().String()
-	query := "INSERT INTO users (id, nip, name, password, role) VALUES ($1, $2, $3, $4, $5)"
-	_, err = r.DB.Exec(ctx, query, userId, strconv.Itoa(payload.NIP), &payload.Name, &payload.Password, user_entity.IT)
+	query := "INSERT INTO users (id, nip, name, password, role, must_change_password) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err = r.DB.Exec(ctx, query, userId, payload.NIP, &payload.Name, &payload.Password, user_entity.IT, &payload.MustChangePassword)
+	if isUniqueViolation(err) {
+		return "", user_error.ErrNIPAlreadyExists
+	}
+	if err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+// CreateFirstITUser only inserts while no IT user exists. Concurrent bootstrap
+// requests are serialized on the IT users lock so only one of them wins.
+func (r *UserRepositoryPostgres) CreateFirstITUser(ctx context.Context, payload *user_entity.RegisterITUser) (userId string, err error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('users:it'))")
+	if err != nil {
+		return "", err
+	}
+
+	userId = ulid.Make().String()
+	query := `INSERT INTO users (id, nip, name, password, role, must_change_password)
+							SELECT $1, $2, $3, $4, $5, $6
+							WHERE NOT EXISTS (SELECT 1 FROM users WHERE role = $5 AND is_deleted = false)`
+	tag, err := tx.Exec(ctx, query, userId, payload.NIP, &payload.Name, &payload.Password, user_entity.IT, &payload.MustChangePassword)
+	if isUniqueViolation(err) {
+		return "", user_error.ErrNIPAlreadyExists
+	}
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", user_error.ErrRegistrationClosed
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return "", err
 	}
 	return userId, nil
 }
 
-func (r *UserRepositoryPostgres) CreateNurseUser(ctx context.Context, payload *user_entity.RegisterNurseUser) (userId string, err error) {
+func (r *UserRepositoryPostgres) CreateStaffUser(ctx context.Context, role user_entity.Role, payload *user_entity.RegisterStaffUser) (userId string, err error) {
 	userId = ulid.Make().String()
 	query := "INSERT INTO users (id, nip, name, card_image_url, role) VALUES ($1, $2, $3, $4, $5)"
-	_, err = r.DB.Exec(ctx, query, userId, strconv.Itoa(payload.NIP), &payload.Name, &payload.CardImageURL, user_entity.Nurse)
+	_, err = r.DB.Exec(ctx, query, userId, payload.NIP, &payload.Name, &payload.CardImageURL, role)
+	if isUniqueViolation(err) {
+		return "", user_error.ErrNIPAlreadyExists
+	}
 	if err != nil {
 		return "", err
 	}
 	return userId, nil
 }
 
-func (r *UserRepositoryPostgres) GetUserByNIP(ctx context.Context, nip int) (user *user_entity.User, err error) {
+func (r *UserRepositoryPostgres) GetUserByNIP(ctx context.Context, nip nip.NIP) (user *user_entity.User, err error) {
 	user = &user_entity.User{}
-	var nipStr string
-	query := "SELECT id, nip, name, password, role FROM users WHERE nip = $1"
-	err = r.DB.QueryRow(ctx, query, strconv.Itoa(nip)).Scan(&user.ID, &nipStr, &user.Name, &user.Password, &user.Role)
+	query := `SELECT id, nip, name, COALESCE(password, ''), role, must_change_password, mfa_enabled, is_disabled, is_deleted, access_expires_at
+							FROM users WHERE nip = $1 AND is_deleted = false`
+	err = r.DB.QueryRow(ctx, query, nip).Scan(&user.ID, &user.NIP, &user.Name, &user.Password, &user.Role, &user.MustChangePassword, &user.MFAEnabled, &user.IsDisabled, &user.IsDeleted, &user.AccessExpiresAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, user_error.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	nip, err = strconv.Atoi(nipStr)
-	if err != nil {
-		return nil, err
-	}
-	user.NIP = nip
 	return user, nil
 }
 
 func (r *UserRepositoryPostgres) GetUserByID(ctx context.Context, id string) (user *user_entity.User, err error) {
 	user = &user_entity.User{}
-	var nipStr string
-	query := "SELECT id, nip, name, role FROM users WHERE id = $1"
-	err = r.DB.QueryRow(ctx, query, id).Scan(&user.ID, &nipStr, &user.Name, &user.Role)
+	query := `SELECT id, nip, name, COALESCE(password, ''), role, must_change_password, mfa_enabled, is_disabled, is_deleted, access_expires_at
+							FROM users WHERE id = $1 AND is_deleted = false`
+	err = r.DB.QueryRow(ctx, query, id).Scan(&user.ID, &user.NIP, &user.Name, &user.Password, &user.Role, &user.MustChangePassword, &user.MFAEnabled, &user.IsDisabled, &user.IsDeleted, &user.AccessExpiresAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, user_error.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	nip, err := strconv.Atoi(nipStr)
+	return user, nil
+}
+
+// GetUserByIDIncludingDeleted also finds soft deleted users, for the admin
+// paths that act on them such as restore.
+func (r *UserRepositoryPostgres) GetUserByIDIncludingDeleted(ctx context.Context, id string) (user *user_entity.User, err error) {
+	user = &user_entity.User{}
+	query := `SELECT id, nip, name, COALESCE(password, ''), role, must_change_password, mfa_enabled, is_disabled, is_deleted, access_expires_at
+							FROM users WHERE id = $1`
+	err = r.DB.QueryRow(ctx, query, id).Scan(&user.ID, &user.NIP, &user.Name, &user.Password, &user.Role, &user.MustChangePassword, &user.MFAEnabled, &user.IsDisabled, &user.IsDeleted, &user.AccessExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, user_error.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	user.NIP = nip
 	return user, nil
 }
 
-func (r *UserRepositoryPostgres) GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, error) {
-	query := "SELECT id, nip, name, created_at FROM users WHERE is_deleted = false"
-	args := []interface{}{}
-	argID := 1
+func (r *UserRepositoryPostgres) GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, int, error) {
+	query := "SELECT id, nip, name, created_at, deleted_at, COUNT(*) OVER() FROM users WHERE is_deleted = $1"
+	args := []interface{}{params.Deleted}
+	argID := 2
 
 	if params.UserID != "" {
 		query += " AND id = $" + strconv.Itoa(argID)
@@ -116,13 +208,33 @@ func (r *UserRepositoryPostgres) GetUsers(ctx context.Context, params *user_enti
 		argID++
 	}
 
-	switch params.Role {
-	case "it":
-		query += " AND role = 'it'"
-	case "nurse":
-		query += " AND role = 'nurse'"
+	// NIP components are fixed width: gender digit, then year and month.
+	if params.Gender != 0 {
+		query += " AND SUBSTRING(nip FROM 4 FOR 1) = $" + strconv.Itoa(argID)
+		args = append(args, strconv.Itoa(int(params.Gender)))
+		argID++
+	}
+
+	if params.NIPYear != 0 {
+		query += " AND SUBSTRING(nip FROM 5 FOR 4) = $" + strconv.Itoa(argID)
+		args = append(args, fmt.Sprintf("%04d", params.NIPYear))
+		argID++
+	}
+
+	if params.NIPMonth != 0 {
+		query += " AND SUBSTRING(nip FROM 9 FOR 2) = $" + strconv.Itoa(argID)
+		args = append(args, fmt.Sprintf("%02d", int(params.NIPMonth)))
+		argID++
 	}
 
+	if role := user_entity.Role(params.Role); role.IsValid() {
+		query += " AND role = $" + strconv.Itoa(argID)
+		args = append(args, role)
+		argID++
+	}
+
+	filterQuery, filterArgs := query, args
+
 	switch params.CreatedAt {
 	case "asc":
 		query += " ORDER BY created_at ASC"
@@ -132,43 +244,54 @@ func (r *UserRepositoryPostgres) GetUsers(ctx context.Context, params *user_enti
 
 	query += " LIMIT $" + strconv.Itoa(argID) + " OFFSET $" + strconv.Itoa(argID+1)
 	args = append(args, params.Limit, params.Offset)
-	fmt.Println(query)
 
 	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
+	var total int
 	users := []*user_entity.UserList{}
 	for rows.Next() {
 		var user user_entity.UserList
-		var nipStr string
-		if err := rows.Scan(&user.ID, &nipStr, &user.Name, &user.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&user.ID, &user.NIP, &user.Name, &user.CreatedAt, &user.DeletedAt, &total); err != nil {
+			return nil, 0, err
 		}
-		nip, err := strconv.Atoi(nipStr)
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(users) == 0 && params.Offset > 0 {
+		total, err = countFiltered(ctx, r.DB, filterQuery, filterArgs)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		user.NIP = nip
-		users = append(users, &user)
 	}
 
-	return users, nil
+	return users, total, nil
 }
 
-func (r *UserRepositoryPostgres) UpdateNurseUser(ctx context.Context, payload *user_entity.UpdateNurseUser) error {
-	query := "UPDATE users SET nip = $1, name = $2 WHERE id = $3"
-	_, err := r.DB.Exec(ctx, query, strconv.Itoa(payload.NIP), &payload.Name, &payload.UserID)
+func (r *UserRepositoryPostgres) UpdateITUser(ctx context.Context, payload *user_entity.UpdateITUser) error {
+	query := "UPDATE users SET nip = $1, name = $2, updated_at = NOW() WHERE id = $3 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, payload.NIP, &payload.Name, &payload.UserID)
+	if isUniqueViolation(err) {
+		return user_error.ErrNIPAlreadyExists
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *UserRepositoryPostgres) DeleteNurseUser(ctx context.Context, userId string) error {
-	query := "UPDATE users SET is_deleted = true WHERE id = $1"
+func (r *UserRepositoryPostgres) DisableITUser(ctx context.Context, userId string) error {
+	return r.removeITUser(ctx, userId, "UPDATE users SET is_disabled = true, updated_at = NOW() WHERE id = $1 AND is_deleted = false")
+}
+
+func (r *UserRepositoryPostgres) EnableUser(ctx context.Context, userId string) error {
+	query := "UPDATE users SET is_disabled = false, updated_at = NOW() WHERE id = $1 AND is_deleted = false"
 	_, err := r.DB.Exec(ctx, query, userId)
 	if err != nil {
 		return err
@@ -176,11 +299,143 @@ func (r *UserRepositoryPostgres) DeleteNurseUser(ctx context.Context, userId str
 	return nil
 }
 
-func (r *UserRepositoryPostgres) GiveAccessNurseUser(ctx context.Context, payload *user_entity.GiveAccessNurseUser) error {
-	query := "UPDATE users SET password = $1 WHERE id = $2"
-	_, err := r.DB.Exec(ctx, query, &payload.Password, &payload.UserID)
+func (r *UserRepositoryPostgres) DeleteITUser(ctx context.Context, userId string) error {
+	return r.removeITUser(ctx, userId, "UPDATE users SET is_deleted = true, deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND is_deleted = false")
+}
+
+// removeITUser runs query unless userId is the last IT user able to log in.
+// The advisory lock keeps two admins from removing each other at once.
+func (r *UserRepositoryPostgres) removeITUser(ctx context.Context, userId, query string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('users:it'))")
+	if err != nil {
+		return err
+	}
+
+	var remaining int
+	countQuery := `SELECT COUNT(*) FROM users
+									WHERE role = $1 AND id <> $2 AND is_deleted = false AND is_disabled = false AND password IS NOT NULL
+										AND (access_expires_at IS NULL OR access_expires_at > NOW())`
+	err = tx.QueryRow(ctx, countQuery, user_entity.IT, userId).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return user_error.ErrLastITUser
+	}
+
+	_, err = tx.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *UserRepositoryPostgres) UpdateStaffUser(ctx context.Context, payload *user_entity.UpdateStaffUser) error {
+	query := "UPDATE users SET nip = $1, name = $2, updated_at = NOW() WHERE id = $3 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, payload.NIP, &payload.Name, &payload.UserID)
+	if isUniqueViolation(err) {
+		return user_error.ErrNIPAlreadyExists
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (r *UserRepositoryPostgres) DeleteStaffUser(ctx context.Context, userId string) error {
+	query := "UPDATE users SET is_deleted = true, deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestoreUser undeletes userId. It fails with ErrNIPAlreadyExists when the NIP
+// has since been given to another active user.
+func (r *UserRepositoryPostgres) RestoreUser(ctx context.Context, userId string) error {
+	query := "UPDATE users SET is_deleted = false, deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND is_deleted = true"
+	tag, err := r.DB.Exec(ctx, query, userId)
+	if isUniqueViolation(err) {
+		return user_error.ErrNIPAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return user_error.ErrUserNotDeleted
+	}
+	return nil
+}
+
+func (r *UserRepositoryPostgres) GiveAccessStaffUser(ctx context.Context, payload *user_entity.GiveAccessStaffUser) error {
+	query := "UPDATE users SET password = $1, access_expires_at = $2, updated_at = NOW() WHERE id = $3 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, &payload.Password, payload.AccessExpiresAt, &payload.UserID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeAccessStaffUser clears the password so the user can no longer log in.
+// The row is kept, so past medical records stay attributed to them.
+func (r *UserRepositoryPostgres) RevokeAccessStaffUser(ctx context.Context, userId string) error {
+	query := "UPDATE users SET password = NULL, access_expires_at = NULL, updated_at = NOW() WHERE id = $1 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepositoryPostgres) UpdatePassword(ctx context.Context, userId, password string, mustChangePassword bool) error {
+	query := "UPDATE users SET password = $1, must_change_password = $2, updated_at = NOW() WHERE id = $3 AND is_deleted = false"
+	_, err := r.DB.Exec(ctx, query, password, mustChangePassword, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepositoryPostgres) GetPasswordHistory(ctx context.Context, userId string, limit int) ([]string, error) {
+	query := "SELECT password FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2"
+	rows, err := r.DB.Query(ctx, query, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passwords := []string{}
+	for rows.Next() {
+		var password string
+		if err := rows.Scan(&password); err != nil {
+			return nil, err
+		}
+		passwords = append(passwords, password)
+	}
+
+	return passwords, rows.Err()
+}
+
+func (r *UserRepositoryPostgres) AddPasswordHistory(ctx context.Context, userId, password string) error {
+	query := "INSERT INTO password_history (id, user_id, password) VALUES ($1, $2, $3)"
+	_, err := r.DB.Exec(ctx, query, ulid.Make().String(), userId, password)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err was raised by a unique index, such as
+// the one on active NIPs.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,124 @@
+package repository_postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
+)
+
+func TestAllocateNIP(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepositoryPostgres{DB: newTestDB(t)}
+
+	// Sequence 3 was entered by hand, so allocation has to skip it.
+	createTestNurse(t, r, 3)
+
+	want := []int{1, 2, 4}
+	for _, sequence := range want {
+		allocated, err := r.AllocateNIP(ctx, nip.PrefixNurse, nip.Male, 2024, time.March)
+		if err != nil {
+			t.Fatalf("AllocateNIP: %v", err)
+		}
+		if allocated != mustNIP(t, nip.PrefixNurse, sequence) {
+			t.Fatalf("got %d, want sequence %d", allocated, sequence)
+		}
+	}
+
+	// Sequences are kept per month.
+	allocated, err := r.AllocateNIP(ctx, nip.PrefixNurse, nip.Male, 2024, time.April)
+	if err != nil {
+		t.Fatalf("AllocateNIP: %v", err)
+	}
+	if components, _ := allocated.Components(); components.Sequence != "001" {
+		t.Fatalf("April started at %s", components.Sequence)
+	}
+}
+
+func TestActiveNIPUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepositoryPostgres{DB: newTestDB(t)}
+	payload := &user_entity.RegisterStaffUser{
+		NIP:          mustNIP(t, nip.PrefixNurse, 1),
+		Name:         "Nurse User",
+		CardImageURL: "https://example.com/card.png",
+	}
+
+	first := createTestNurse(t, r, 1)
+	if _, err := r.CreateStaffUser(ctx, user_entity.Nurse, payload); !errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		t.Fatalf("duplicate active NIP: got %v, want ErrNIPAlreadyExists", err)
+	}
+
+	if err := r.DeleteStaffUser(ctx, first); err != nil {
+		t.Fatalf("DeleteStaffUser: %v", err)
+	}
+	if _, err := r.CreateStaffUser(ctx, user_entity.Nurse, payload); err != nil {
+		t.Fatalf("NIP of a deleted user: %v", err)
+	}
+
+	if err := r.RestoreUser(ctx, first); !errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		t.Fatalf("RestoreUser over a reused NIP: got %v, want ErrNIPAlreadyExists", err)
+	}
+}
+
+func TestRemoveLastITUserCountsOnlyUsersWhoCanLogIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  string
+		wantErr error
+	}{
+		{"active", "", nil},
+		{"expired", "UPDATE users SET access_expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1", user_error.ErrLastITUser},
+		{"disabled", "UPDATE users SET is_disabled = true WHERE id = $1", user_error.ErrLastITUser},
+		{"without password", "UPDATE users SET password = NULL WHERE id = $1", user_error.ErrLastITUser},
+		{"access until later", "UPDATE users SET access_expires_at = NOW() + INTERVAL '1 hour' WHERE id = $1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := &UserRepositoryPostgres{DB: newTestDB(t)}
+			admin := createTestITUser(t, r, 1)
+			other := createTestITUser(t, r, 2)
+
+			if tt.update != "" {
+				if _, err := r.DB.Exec(ctx, tt.update, other); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := r.DeleteITUser(ctx, admin); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteITUser: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetUsersPagination(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepositoryPostgres{DB: newTestDB(t)}
+	for sequence := 1; sequence <= 7; sequence++ {
+		createTestNurse(t, r, sequence)
+	}
+
+	tests := []struct {
+		offset, rows int
+	}{
+		{0, 5},
+		{5, 2},
+		{10, 0},
+	}
+	for _, tt := range tests {
+		users, total, err := r.GetUsers(ctx, &user_entity.UserQueryParams{Role: string(user_entity.Nurse), Limit: 5, Offset: tt.offset})
+		if err != nil {
+			t.Fatalf("GetUsers: %v", err)
+		}
+		if len(users) != tt.rows || total != 7 {
+			t.Errorf("offset %d: got %d rows of %d, want %d of 7", tt.offset, len(users), total, tt.rows)
+		}
+	}
+}
@@ -3,11 +3,16 @@ package server
 import (
 	"log"
 	"net/http"
+	"os"
 
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
 	"github.com/danzBraham/halo-suster/internal/applications/services"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
 	"github.com/danzBraham/halo-suster/internal/helpers"
+	"github.com/danzBraham/halo-suster/internal/infrastructures/oidc"
 	repository_postgres "github.com/danzBraham/halo-suster/internal/infrastructures/repository"
 	"github.com/danzBraham/halo-suster/internal/interfaces/http/api/controllers"
+	"github.com/danzBraham/halo-suster/internal/interfaces/http/api/middlewares"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -37,18 +42,46 @@ func (s *APIServer) Launch() error {
 		})
 	})
 
+	passwordHasher, err := helpers.NewPasswordHasher()
+	if err != nil {
+		return err
+	}
+
+	passwordPolicy, err := helpers.NewPasswordPolicy()
+	if err != nil {
+		return err
+	}
+
+	var identityProvider interfaces.IdentityProvider
+	oidcConfig, err := oidc.NewConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if oidcConfig != nil {
+		identityProvider = oidc.NewProvider(oidcConfig, nil)
+	}
+
 	// User domain
 	userRepository := repository_postgres.NewUserRepositoryPostgres(s.DB)
-	userService := services.NewUserService(userRepository)
-	userController := controllers.NewUserController(userService)
+	authRepository := repository_postgres.NewAuthRepositoryPostgres(s.DB)
+	userService := services.NewUserService(userRepository, authRepository, passwordHasher, passwordPolicy, identityProvider, os.Getenv("IT_SETUP_TOKEN"))
+	authMiddleware := middlewares.NewAuthMiddleware(userService)
+	userController := controllers.NewUserController(userService, authMiddleware)
 
 	// Medical domain
 	medicalRepository := repository_postgres.NewMedicalRepositoryPostgres(s.DB)
-	medicalService := services.NewMedicalService(medicalRepository)
-	medicalController := controllers.NewMedicalController(medicalService)
+	nikMode, err := nik.ParseMode(os.Getenv("NIK_VALIDATION_MODE"))
+	if err != nil {
+		return err
+	}
+	medicalService := services.NewMedicalService(medicalRepository, nikMode)
+	medicalController := controllers.NewMedicalController(medicalService, authMiddleware)
 
 	// Upload domain
-	uploadController := controllers.NewUploadController()
+	uploadController := controllers.NewUploadController(authMiddleware)
+
+	wellKnownController := controllers.NewWellKnownController()
+	r.Mount("/.well-known", wellKnownController.Routes())
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Mount("/user", userController.Routes())
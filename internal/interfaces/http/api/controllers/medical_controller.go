@@ -2,10 +2,17 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
 	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nik"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
 	medical_error "github.com/danzBraham/halo-suster/internal/exceptions/medicals"
 	"github.com/danzBraham/halo-suster/internal/helpers"
 	"github.com/danzBraham/halo-suster/internal/interfaces/http/api/middlewares"
@@ -14,25 +21,42 @@ import (
 
 type MedicalController struct {
 	MedicalService interfaces.MedicalService
+	AuthMiddleware *middlewares.AuthMiddleware
 }
 
-func NewMedicalController(medicalService interfaces.MedicalService) *MedicalController {
-	return &MedicalController{MedicalService: medicalService}
+func NewMedicalController(medicalService interfaces.MedicalService, authMiddleware *middlewares.AuthMiddleware) *MedicalController {
+	return &MedicalController{
+		MedicalService: medicalService,
+		AuthMiddleware: authMiddleware,
+	}
 }
 
 func (c *MedicalController) Routes() chi.Router {
 	r := chi.NewRouter()
 
-	r.Use(middlewares.AuthMiddleware)
-	r.Post("/patient", c.handleAddMedicalPatient)
-	r.Get("/patient", c.handleGetMedicalPatients)
-	r.Post("/record", c.handleAddMedicalRecord)
-	r.Get("/record", c.handleGetMedicalRecords)
+	r.Use(c.AuthMiddleware.Authenticate)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsWrite)).Post("/patient", c.handleAddMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsRead)).Get("/patient", c.handleGetMedicalPatients)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsRead)).Get("/patient/{identityNumber}", c.handleGetMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsWrite)).Put("/patient/{identityNumber}", c.handleUpdateMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsWrite)).Patch("/patient/{identityNumber}", c.handlePatchMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsWrite)).Delete("/patient/{identityNumber}", c.handleDeleteMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.PatientsWrite)).Post("/patient/{identityNumber}/restore", c.handleRestoreMedicalPatient)
+	r.With(middlewares.RequirePermission(permission_entity.RecordsWrite)).Post("/record", c.handleAddMedicalRecord)
+	r.With(middlewares.RequirePermission(permission_entity.RecordsRead)).Get("/record", c.handleGetMedicalRecords)
 
 	return r
 }
 
 func (c *MedicalController) handleAddMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	credential, ok := r.Context().Value(middlewares.ContextCredentialKey).(*helpers.Credential)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Credential type assertion failed",
+			Message: "Credential not found in context",
+		})
+		return
+	}
 	payload := &medical_entity.AddMedicalPatient{}
 
 	err := helpers.DecodeJSON(r, payload)
@@ -43,6 +67,8 @@ func (c *MedicalController) handleAddMedicalPatient(w http.ResponseWriter, r *ht
 		})
 		return
 	}
+	payload.CreatedBy = credential.UserId
+	payload.APIKeyID = credential.APIKeyID
 
 	err = helpers.ValidatePayload(payload)
 	if err != nil {
@@ -53,8 +79,15 @@ func (c *MedicalController) handleAddMedicalPatient(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = c.MedicalService.CreatePatient(r.Context(), payload)
-	if errors.Is(err, medical_error.ErrIdentityNumberAlreadyExists) {
+	warnings, err := c.MedicalService.CreatePatient(r.Context(), payload)
+	if errors.Is(err, medical_error.ErrIdentityNumberMismatch) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, medical_error.ErrIdentityNumberAlreadyExists) || errors.Is(err, medical_error.ErrPatientIsDeleted) {
 		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
 			Error:   "Conflict error",
 			Message: err.Error(),
@@ -70,35 +103,81 @@ func (c *MedicalController) handleAddMedicalPatient(w http.ResponseWriter, r *ht
 	}
 
 	helpers.ResponseJSON(w, http.StatusCreated, &helpers.ResponseBody{
-		Message: "Medical patient successfully added",
+		Message:  "Medical patient successfully added",
+		Warnings: warnings,
 	})
 }
 
 func (c *MedicalController) handleGetMedicalPatients(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
+	limit, offset, err := helpers.ParsePagination(query)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
 	params := &medical_entity.MedicalPatientParams{
 		IdentityNumber: query.Get("identityNumber"),
-		Limit:          "5",
-		Offset:         "0",
+		Limit:          limit,
+		Offset:         offset,
 		Name:           query.Get("name"),
 		PhoneNumber:    query.Get("phoneNumber"),
-		CreatedAt:      "desc",
+		SortBy:         query.Get("sortBy"),
+		Order:          query.Get("order"),
 	}
 
-	if limit := query.Get("limit"); limit != "" {
-		params.Limit = limit
+	err = parsePatientFilters(query, params)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
 	}
 
-	if offset := query.Get("offset"); offset != "" {
-		params.Offset = offset
+	// createdAt=asc|desc predates sortBy and order.
+	if createdAt := query.Get("createdAt"); createdAt != "" && params.SortBy == "" {
+		params.SortBy = "createdAt"
+		params.Order = createdAt
+	}
+	if params.SortBy == "createdAt" && params.Order == "" {
+		params.Order = "desc"
 	}
 
-	if createdAt := query.Get("createdAt"); createdAt != "" {
-		params.CreatedAt = createdAt
+	medicalPatients, total, err := c.MedicalService.GetMedicalPatients(r.Context(), params)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
 	}
 
-	medicalPatients, err := c.MedicalService.GetMedicalPatients(r.Context(), params)
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message:    "success",
+		Data:       medicalPatients,
+		Pagination: helpers.NewPagination(r, total, limit, offset),
+	})
+}
+
+func (c *MedicalController) handleGetMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	identityNumber, ok := identityNumberParam(w, r)
+	if !ok {
+		return
+	}
+
+	patient, err := c.MedicalService.GetMedicalPatient(r.Context(), identityNumber)
+	if errors.Is(err, medical_error.ErrPatientNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
 			Error:   "Internal server error",
@@ -109,20 +188,171 @@ func (c *MedicalController) handleGetMedicalPatients(w http.ResponseWriter, r *h
 
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
 		Message: "success",
-		Data:    medicalPatients,
+		Data:    patient,
+	})
+}
+
+func (c *MedicalController) handleUpdateMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	identityNumber, ok := identityNumberParam(w, r)
+	if !ok {
+		return
+	}
+	payload := &medical_entity.UpdateMedicalPatient{}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.IdentityNumber = identityNumber
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	patient, warnings, err := c.MedicalService.UpdatePatient(r.Context(), payload)
+	respondPatientUpdated(w, patient, warnings, err)
+}
+
+func (c *MedicalController) handlePatchMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	identityNumber, ok := identityNumberParam(w, r)
+	if !ok {
+		return
+	}
+	payload := &medical_entity.PatchMedicalPatient{}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.IdentityNumber = identityNumber
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	patient, warnings, err := c.MedicalService.PatchPatient(r.Context(), payload)
+	respondPatientUpdated(w, patient, warnings, err)
+}
+
+func respondPatientUpdated(w http.ResponseWriter, patient *medical_entity.MedicalPatientDetail, warnings []string, err error) {
+	if errors.Is(err, medical_error.ErrIdentityNumberMismatch) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, medical_error.ErrPatientNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message:  "Medical patient successfully updated",
+		Warnings: warnings,
+		Data:     patient,
+	})
+}
+
+func (c *MedicalController) handleDeleteMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	identityNumber, ok := identityNumberParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.MedicalService.DeletePatient(r.Context(), identityNumber)
+	if errors.Is(err, medical_error.ErrPatientNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "Medical patient successfully deleted",
+	})
+}
+
+func (c *MedicalController) handleRestoreMedicalPatient(w http.ResponseWriter, r *http.Request) {
+	identityNumber, ok := identityNumberParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.MedicalService.RestorePatient(r.Context(), identityNumber)
+	if errors.Is(err, medical_error.ErrPatientNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, medical_error.ErrPatientNotDeleted) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "Medical patient successfully restored",
 	})
 }
 
 func (c *MedicalController) handleAddMedicalRecord(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	credential, ok := r.Context().Value(middlewares.ContextCredentialKey).(*helpers.Credential)
 	if !ok {
 		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
-			Error:   "User ID type assertion failed",
-			Message: "User ID not found in context",
+			Error:   "Credential type assertion failed",
+			Message: "Credential not found in context",
 		})
 		return
 	}
-	payload := &medical_entity.AddMedicalRecord{UserID: userID}
+	payload := &medical_entity.AddMedicalRecord{}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -132,6 +362,9 @@ func (c *MedicalController) handleAddMedicalRecord(w http.ResponseWriter, r *htt
 		})
 		return
 	}
+	// The actor always comes from the credential, never from the body.
+	payload.UserID = credential.UserId
+	payload.APIKeyID = credential.APIKeyID
 
 	err = helpers.ValidatePayload(payload)
 	if err != nil {
@@ -142,6 +375,14 @@ func (c *MedicalController) handleAddMedicalRecord(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if payload.Diagnosis != "" && !middlewares.Can(r, permission_entity.DiagnosesWrite) {
+		helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+			Error:   "Forbidden error",
+			Message: auth_error.ErrPermissionDenied.Error(),
+		})
+		return
+	}
+
 	err = c.MedicalService.CreateMedicalRecord(r.Context(), payload)
 	if errors.Is(err, medical_error.ErrIdentityNumberIsNotExists) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
@@ -166,28 +407,29 @@ func (c *MedicalController) handleAddMedicalRecord(w http.ResponseWriter, r *htt
 func (c *MedicalController) handleGetMedicalRecords(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
+	limit, offset, err := helpers.ParsePagination(query)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
 	params := &medical_entity.MedicalRecordParams{
 		IdentityNumber: query.Get("identityDetail.identityNumber"),
 		UserID:         query.Get("createdBy.userId"),
 		NIP:            query.Get("createdBy.nip"),
-		Limit:          "5",
-		Offset:         "0",
+		Limit:          limit,
+		Offset:         offset,
 		CreatedAt:      "desc",
 	}
 
-	if limit := query.Get("limit"); limit != "" {
-		params.Limit = limit
-	}
-
-	if offset := query.Get("offset"); offset != "" {
-		params.Offset = offset
-	}
-
 	if createdAt := query.Get("createdAt"); createdAt != "" {
 		params.CreatedAt = createdAt
 	}
 
-	medicalRecords, err := c.MedicalService.GetMedicalRecords(r.Context(), params)
+	medicalRecords, total, err := c.MedicalService.GetMedicalRecords(r.Context(), params)
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
 			Error:   "Internal server error",
@@ -197,7 +439,94 @@ func (c *MedicalController) handleGetMedicalRecords(w http.ResponseWriter, r *ht
 	}
 
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "success",
-		Data:    medicalRecords,
+		Message:    "success",
+		Data:       medicalRecords,
+		Pagination: helpers.NewPagination(r, total, limit, offset),
 	})
 }
+
+// parsePatientFilters reads the province, gender, birth date and age filters.
+func parsePatientFilters(query url.Values, params *medical_entity.MedicalPatientParams) error {
+	if value := query.Get("province"); value != "" {
+		if nik.LookupProvince(value) == nil {
+			return fmt.Errorf("province must be a known two digit province code")
+		}
+		params.ProvinceCode = value
+	}
+
+	if value := query.Get("gender"); value != "" {
+		gender := medical_entity.Gender(value)
+		if gender != medical_entity.Male && gender != medical_entity.Female {
+			return fmt.Errorf("gender must be male or female")
+		}
+		params.Gender = gender
+	}
+
+	if value := query.Get("birthDateFrom"); value != "" {
+		from, ok := parseDateParam(value)
+		if !ok {
+			return fmt.Errorf("birthDateFrom must be a date or an RFC 3339 timestamp")
+		}
+		params.BirthDateFrom = &from
+	}
+
+	if value := query.Get("birthDateTo"); value != "" {
+		to, ok := parseDateParam(value)
+		if !ok {
+			return fmt.Errorf("birthDateTo must be a date or an RFC 3339 timestamp")
+		}
+		params.BirthDateTo = &to
+	}
+
+	if params.BirthDateFrom != nil && params.BirthDateTo != nil && params.BirthDateFrom.After(*params.BirthDateTo) {
+		return fmt.Errorf("birthDateFrom must not be after birthDateTo")
+	}
+
+	if value := query.Get("minAge"); value != "" {
+		minAge, err := strconv.Atoi(value)
+		if err != nil || minAge < 0 {
+			return fmt.Errorf("minAge must be a non-negative integer")
+		}
+		params.MinAge = &minAge
+	}
+
+	if value := query.Get("maxAge"); value != "" {
+		maxAge, err := strconv.Atoi(value)
+		if err != nil || maxAge < 0 {
+			return fmt.Errorf("maxAge must be a non-negative integer")
+		}
+		params.MaxAge = &maxAge
+	}
+
+	if params.MinAge != nil && params.MaxAge != nil && *params.MinAge > *params.MaxAge {
+		return fmt.Errorf("minAge must not be greater than maxAge")
+	}
+
+	return nil
+}
+
+// parseDateParam accepts a plain date or a full RFC 3339 timestamp.
+func parseDateParam(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, true
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+func identityNumberParam(w http.ResponseWriter, r *http.Request) (nik.NIK, bool) {
+	identityNumber, err := nik.Parse(chi.URLParam(r, "identityNumber"))
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: "Request doesn’t pass validation",
+		})
+		return "", false
+	}
+	return identityNumber, true
+}
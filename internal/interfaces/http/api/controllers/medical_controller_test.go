@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
+	medical_entity "github.com/danzBraham/halo-suster/internal/domains/entities/medicals"
+)
+
+// fakeMedicalService records the query parameters GetMedicalPatients was
+// called with.
+type fakeMedicalService struct {
+	interfaces.MedicalService
+	params *medical_entity.MedicalPatientParams
+}
+
+func (s *fakeMedicalService) GetMedicalPatients(ctx context.Context, params *medical_entity.MedicalPatientParams) ([]*medical_entity.MedicalPatient, int, error) {
+	s.params = params
+	return []*medical_entity.MedicalPatient{}, 0, nil
+}
+
+func TestHandleGetMedicalPatientsFilters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"province=32&gender=female&birthDateFrom=1990-01-01&birthDateTo=1990-12-31T23:59:59Z&minAge=18&maxAge=65", http.StatusOK},
+		{"minAge=30&maxAge=30", http.StatusOK},
+		{"birthDateFrom=1990-01-01&birthDateTo=1990-01-01", http.StatusOK},
+		{"province=99", http.StatusBadRequest},
+		{"province=jabar", http.StatusBadRequest},
+		{"gender=other", http.StatusBadRequest},
+		{"birthDateFrom=01-01-1990", http.StatusBadRequest},
+		{"birthDateTo=yesterday", http.StatusBadRequest},
+		{"birthDateFrom=1991-01-01&birthDateTo=1990-01-01", http.StatusBadRequest},
+		{"minAge=-1", http.StatusBadRequest},
+		{"maxAge=old", http.StatusBadRequest},
+		{"minAge=40&maxAge=30", http.StatusBadRequest},
+		{"offset=-1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			service := &fakeMedicalService{}
+			controller := &MedicalController{MedicalService: service}
+
+			w := httptest.NewRecorder()
+			controller.handleGetMedicalPatients(w, httptest.NewRequest(http.MethodGet, "/v1/medical/patient?"+tt.query, nil))
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK && service.params != nil {
+				t.Fatal("service called for an invalid query")
+			}
+		})
+	}
+}
+
+func TestParsePatientFilters(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/medical/patient?province=32&gender=male&birthDateFrom=1990-01-01&minAge=18", nil)
+	params := &medical_entity.MedicalPatientParams{}
+
+	if err := parsePatientFilters(r.URL.Query(), params); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if params.ProvinceCode != "32" || params.Gender != medical_entity.Male ||
+		params.BirthDateFrom == nil || !params.BirthDateFrom.Equal(from) || params.BirthDateTo != nil ||
+		params.MinAge == nil || *params.MinAge != 18 || params.MaxAge != nil {
+		t.Fatalf("unexpected params %+v", params)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
 	upload_entity "github.com/danzBraham/halo-suster/internal/domains/entities/uploads"
 	"github.com/danzBraham/halo-suster/internal/helpers"
 	"github.com/danzBraham/halo-suster/internal/interfaces/http/api/middlewares"
@@ -15,17 +16,19 @@ import (
 	"github.com/google/uuid"
 )
 
-type UploadController struct{}
+type UploadController struct {
+	AuthMiddleware *middlewares.AuthMiddleware
+}
 
-func NewUploadController() *UploadController {
-	return &UploadController{}
+func NewUploadController(authMiddleware *middlewares.AuthMiddleware) *UploadController {
+	return &UploadController{AuthMiddleware: authMiddleware}
 }
 
 func (c *UploadController) Routes() chi.Router {
 	r := chi.NewRouter()
 
-	r.Use(middlewares.AuthMiddleware)
-	r.Post("/image", c.handleUploadImage)
+	r.Use(c.AuthMiddleware.Authenticate)
+	r.With(middlewares.RequirePermission(permission_entity.ImagesUpload)).Post("/image", c.handleUploadImage)
 
 	return r
 }
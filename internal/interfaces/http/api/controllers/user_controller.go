@@ -1,13 +1,23 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
+	auth_entity "github.com/danzBraham/halo-suster/internal/domains/entities/auths"
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
 	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
 	user_error "github.com/danzBraham/halo-suster/internal/exceptions/users"
 	"github.com/danzBraham/halo-suster/internal/helpers"
 	"github.com/danzBraham/halo-suster/internal/interfaces/http/api/middlewares"
@@ -15,11 +25,15 @@ import (
 )
 
 type UserController struct {
-	Service interfaces.UserService
+	Service        interfaces.UserService
+	AuthMiddleware *middlewares.AuthMiddleware
 }
 
-func NewUserController(userService interfaces.UserService) *UserController {
-	return &UserController{Service: userService}
+func NewUserController(userService interfaces.UserService, authMiddleware *middlewares.AuthMiddleware) *UserController {
+	return &UserController{
+		Service:        userService,
+		AuthMiddleware: authMiddleware,
+	}
 }
 
 func (c *UserController) Routes() chi.Router {
@@ -27,22 +41,72 @@ func (c *UserController) Routes() chi.Router {
 
 	r.Post("/it/register", c.handleRegisterITUser)
 	r.Post("/it/login", c.handleLoginITUser)
-	r.Post("/nurse/login", c.handleLoginNurseUser)
+	r.Post("/it/login/mfa", c.handleLoginMFA)
+	r.Post("/nurse/login", forRole(user_entity.Nurse, c.handleLoginStaffUser))
+	r.Post("/staff/{role}/login", forStaffRole(c.handleLoginStaffUser))
+	r.Post("/token/refresh", c.handleRefreshToken)
+	r.Get("/oidc/login", c.handleOIDCLogin)
+	r.Get("/oidc/callback", c.handleOIDCCallback)
+
+	r.Group(func(r chi.Router) {
+		r.Use(c.AuthMiddleware.AllowPendingPasswordChange)
+		r.Post("/logout", c.handleLogoutUser)
+		r.Put("/me/password", c.handleChangePassword)
+	})
 
 	r.Group(func(r chi.Router) {
-		r.Use(middlewares.AuthMiddleware)
-		r.Post("/nurse/register", c.handleRegisterNurseUser)
-		r.Get("/", c.handleGetUsers)
-		r.Put("/nurse/{userId}", c.handleUpdateNurseUser)
-		r.Delete("/nurse/{userId}", c.handleDeleteNurseUser)
-		r.Post("/nurse/{userId}/access", c.handleGiveAccessNurseUser)
+		r.Use(c.AuthMiddleware.Authenticate)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireUserSession)
+			r.Get("/me/sessions", c.handleGetMySessions)
+			r.Delete("/me/sessions/{sessionId}", c.handleTerminateMySession)
+			r.Post("/me/mfa", c.handleEnrollMFA)
+			r.Post("/me/mfa/activate", c.handleActivateMFA)
+			r.Delete("/me/mfa", c.handleDisableMFA)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.RequirePermission(permission_entity.APIKeysManage))
+				r.Post("/api-keys", c.handleCreateAPIKey)
+				r.Get("/api-keys", c.handleGetAPIKeys)
+				r.Delete("/api-keys/{apiKeyId}", c.handleRevokeAPIKey)
+			})
+		})
+
+		r.With(middlewares.RequirePermission(permission_entity.UsersRead)).Get("/", c.handleGetUsers)
+		r.With(middlewares.RequirePermission(permission_entity.UsersRead)).Get("/{userId}/sessions", c.handleGetUserSessions)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequirePermission(permission_entity.UsersWrite))
+			r.Post("/it", c.handleAddITUser)
+			r.Put("/it/{userId}", c.handleUpdateITUser)
+			r.Post("/it/{userId}/disable", c.handleDisableITUser)
+			r.Post("/it/{userId}/enable", c.handleEnableITUser)
+			r.Delete("/it/{userId}", c.handleDeleteITUser)
+			r.Post("/nurse/register", forRole(user_entity.Nurse, c.handleRegisterStaffUser))
+			r.Put("/nurse/{userId}", forRole(user_entity.Nurse, c.handleUpdateStaffUser))
+			r.Delete("/nurse/{userId}", forRole(user_entity.Nurse, c.handleDeleteStaffUser))
+			r.Post("/nurse/{userId}/restore", forRole(user_entity.Nurse, c.handleRestoreStaffUser))
+			r.Post("/nurse/{userId}/access", forRole(user_entity.Nurse, c.handleGiveAccessStaffUser))
+			r.Delete("/nurse/{userId}/access", forRole(user_entity.Nurse, c.handleRevokeAccessStaffUser))
+			r.Post("/staff/{role}/register", forStaffRole(c.handleRegisterStaffUser))
+			r.Put("/staff/{role}/{userId}", forStaffRole(c.handleUpdateStaffUser))
+			r.Delete("/staff/{role}/{userId}", forStaffRole(c.handleDeleteStaffUser))
+			r.Post("/staff/{role}/{userId}/restore", forStaffRole(c.handleRestoreStaffUser))
+			r.Post("/staff/{role}/{userId}/access", forStaffRole(c.handleGiveAccessStaffUser))
+			r.Delete("/staff/{role}/{userId}/access", forStaffRole(c.handleRevokeAccessStaffUser))
+			r.Delete("/{userId}/sessions", c.handleRevokeUserSessions)
+			r.Delete("/{userId}/sessions/{sessionId}", c.handleTerminateUserSession)
+			r.Delete("/{userId}/lockout", c.handleUnlockUser)
+			r.Post("/{userId}/password/reset", c.handleResetPassword)
+		})
 	})
 
 	return r
 }
 
 func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Request) {
-	payload := &user_entity.RegisterITUser{}
+	payload := &user_entity.RegisterITUser{Client: sessionClient(r)}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -62,7 +126,7 @@ func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if strconv.Itoa(payload.NIP)[:3] != "615" {
+	if payload.NIP != 0 && payload.NIP.Prefix() != nip.PrefixIT {
 		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
 			Error:   "Validation error",
 			Message: "Request doesn’t pass validation",
@@ -70,7 +134,25 @@ func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	payload.SetupToken = r.Header.Get("X-Setup-Token")
+
 	user, err := c.Service.CreateITUser(r.Context(), payload)
+	if errors.Is(err, user_error.ErrRegistrationClosed) {
+		helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+			Error:   "Forbidden error",
+			Message: err.Error(),
+		})
+		return
+	}
+	var policyErr *user_error.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+			Data:    policyErr.Violations,
+		})
+		return
+	}
 	if errors.Is(err, user_error.ErrUserNotFound) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
@@ -78,7 +160,7 @@ func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Req
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) || errors.Is(err, user_error.ErrNIPSequenceExhausted) {
 		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
 			Error:   "Conflict error",
 			Message: err.Error(),
@@ -93,12 +175,7 @@ func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:    "Authorization",
-		Value:   user.AccessToken,
-		Expires: time.Now().Add(2 * time.Hour),
-	}
-	http.SetCookie(w, cookie)
+	setSessionCookies(w, user)
 
 	helpers.ResponseJSON(w, http.StatusCreated, &helpers.ResponseBody{
 		Message: "User successfully registered",
@@ -106,17 +183,8 @@ func (c *UserController) handleRegisterITUser(w http.ResponseWriter, r *http.Req
 	})
 }
 
-func (c *UserController) handleRegisterNurseUser(w http.ResponseWriter, r *http.Request) {
-	role := r.Context().Value(middlewares.ContextRoleKey)
-	if role != user_entity.IT {
-		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
-			Error:   "Unauthorized error",
-			Message: user_error.ErrUserIsNotIT.Error(),
-		})
-		return
-	}
-
-	payload := &user_entity.RegisterNurseUser{}
+func (c *UserController) handleAddITUser(w http.ResponseWriter, r *http.Request) {
+	payload := &user_entity.RegisterITUser{}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -136,7 +204,7 @@ func (c *UserController) handleRegisterNurseUser(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if strconv.Itoa(payload.NIP)[:3] != "303" {
+	if payload.NIP != 0 && payload.NIP.Prefix() != nip.PrefixIT {
 		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
 			Error:   "Validation error",
 			Message: "Request doesn’t pass validation",
@@ -144,15 +212,17 @@ func (c *UserController) handleRegisterNurseUser(w http.ResponseWriter, r *http.
 		return
 	}
 
-	user, err := c.Service.CreateNurseUser(r.Context(), payload)
-	if errors.Is(err, user_error.ErrUserNotFound) {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
+	user, err := c.Service.AddITUser(r.Context(), payload)
+	var policyErr *user_error.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
 			Message: err.Error(),
+			Data:    policyErr.Violations,
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) || errors.Is(err, user_error.ErrNIPSequenceExhausted) {
 		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
 			Error:   "Conflict error",
 			Message: err.Error(),
@@ -167,21 +237,15 @@ func (c *UserController) handleRegisterNurseUser(w http.ResponseWriter, r *http.
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:    "Authorization",
-		Value:   user.AccessToken,
-		Expires: time.Now().Add(2 * time.Hour),
-	}
-	http.SetCookie(w, cookie)
-
 	helpers.ResponseJSON(w, http.StatusCreated, &helpers.ResponseBody{
-		Message: "User successfully registered",
+		Message: "IT user successfully added",
 		Data:    user,
 	})
 }
 
-func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Request) {
-	payload := &user_entity.LoginUser{}
+func (c *UserController) handleUpdateITUser(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "userId")
+	payload := &user_entity.UpdateITUser{}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -191,6 +255,7 @@ func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Reques
 		})
 		return
 	}
+	payload.UserID = userId
 
 	err = helpers.ValidatePayload(payload)
 	if err != nil {
@@ -201,29 +266,65 @@ func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if strconv.Itoa(payload.NIP)[:3] != "615" {
+	if payload.NIP.Prefix() != nip.PrefixIT {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	err = c.Service.UpdateITUser(r.Context(), payload)
+	if errors.Is(err, user_error.ErrUserNotFound) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
-			Message: user_error.ErrUserIsNotIT.Error(),
+			Message: err.Error(),
 		})
 		return
 	}
-
-	user, err := c.Service.LoginUser(r.Context(), payload)
-	if errors.Is(err, user_error.ErrNIPIsNotExists) {
+	if errors.Is(err, user_error.ErrUserIsNotIT) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrInvalidPassword) {
-		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
-			Error:   "Bad request error",
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
 			Message: err.Error(),
 		})
 		return
 	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "IT user successfully updated",
+	})
+}
+
+func (c *UserController) handleDisableITUser(w http.ResponseWriter, r *http.Request) {
+	c.changeITUser(w, r, c.Service.DisableITUser, "IT user successfully disabled")
+}
+
+func (c *UserController) handleEnableITUser(w http.ResponseWriter, r *http.Request) {
+	c.changeITUser(w, r, c.Service.EnableITUser, "IT user successfully enabled")
+}
+
+func (c *UserController) handleDeleteITUser(w http.ResponseWriter, r *http.Request) {
+	c.changeITUser(w, r, c.Service.DeleteITUser, "IT user successfully deleted")
+}
+
+func (c *UserController) changeITUser(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userId string) error, message string) {
+	userId := chi.URLParam(r, "userId")
+
+	err := change(r.Context(), userId)
 	if errors.Is(err, user_error.ErrUserNotFound) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
@@ -231,6 +332,20 @@ func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Reques
 		})
 		return
 	}
+	if errors.Is(err, user_error.ErrUserIsNotIT) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrLastITUser) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
 			Error:   "Internal server error",
@@ -239,21 +354,13 @@ func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:    "Authorization",
-		Value:   user.AccessToken,
-		Expires: time.Now().Add(2 * time.Hour),
-	}
-	http.SetCookie(w, cookie)
-
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "User successfully login",
-		Data:    user,
+		Message: message,
 	})
 }
 
-func (c *UserController) handleLoginNurseUser(w http.ResponseWriter, r *http.Request) {
-	payload := &user_entity.LoginUser{}
+func (c *UserController) handleRegisterStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	payload := &user_entity.RegisterStaffUser{Client: sessionClient(r)}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -267,38 +374,31 @@ func (c *UserController) handleLoginNurseUser(w http.ResponseWriter, r *http.Req
 	err = helpers.ValidatePayload(payload)
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
-			Error:   err.Error(),
+			Error:   "Validation error",
 			Message: "Request doesn’t pass validation",
 		})
 		return
 	}
 
-	if strconv.Itoa(payload.NIP)[:3] != "303" {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
-			Message: user_error.ErrUserIsNotNurse.Error(),
-		})
-		return
-	}
-
-	user, err := c.Service.LoginUser(r.Context(), payload)
-	if errors.Is(err, user_error.ErrInvalidPassword) {
+	if payload.NIP != 0 && payload.NIP.Prefix() != role.NIPPrefix() {
 		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
-			Error:   "Bad request error",
-			Message: err.Error(),
+			Error:   "Validation error",
+			Message: "Request doesn’t pass validation",
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrNIPIsNotExists) {
+
+	user, err := c.Service.CreateStaffUser(r.Context(), role, payload)
+	if errors.Is(err, user_error.ErrUserNotFound) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrUserNotFound) {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) || errors.Is(err, user_error.ErrNIPSequenceExhausted) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
 			Message: err.Error(),
 		})
 		return
@@ -311,45 +411,56 @@ func (c *UserController) handleLoginNurseUser(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:    "Authorization",
-		Value:   user.AccessToken,
-		Expires: time.Now().Add(2 * time.Hour),
-	}
-	http.SetCookie(w, cookie)
-
-	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "User successfully login",
+	helpers.ResponseJSON(w, http.StatusCreated, &helpers.ResponseBody{
+		Message: "User successfully registered",
 		Data:    user,
 	})
 }
 
-func (c *UserController) handleGetUsers(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+func (c *UserController) handleLoginITUser(w http.ResponseWriter, r *http.Request) {
+	payload := &user_entity.LoginUser{Client: sessionClient(r)}
 
-	params := &user_entity.UserQueryParams{
-		UserID:    query.Get("userId"),
-		Limit:     5,
-		Offset:    0,
-		NIP:       query.Get("nip"),
-		Name:      query.Get("name"),
-		Role:      query.Get("role"),
-		CreatedAt: query.Get("createdAt"),
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
 	}
 
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			params.Limit = l
-		}
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
 	}
 
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil {
-			params.Offset = o
-		}
+	if payload.NIP.Prefix() != nip.PrefixIT {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: user_error.ErrUserIsNotIT.Error(),
+		})
+		return
 	}
 
-	users, err := c.Service.GetUsers(r.Context(), params)
+	user, err := c.Service.LoginUser(r.Context(), payload)
+	if errors.Is(err, user_error.ErrInvalidLogin) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrLoginLocked) {
+		helpers.ResponseJSON(w, http.StatusTooManyRequests, &helpers.ResponseBody{
+			Error:   "Too many requests error",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
 			Error:   "Internal server error",
@@ -358,17 +469,24 @@ func (c *UserController) handleGetUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if user.MFARequired {
+		helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+			Message: "MFA code required",
+			Data:    user,
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "success",
-		Data:    users,
+		Message: "User successfully login",
+		Data:    user,
 	})
 }
 
-func (c *UserController) handleUpdateNurseUser(w http.ResponseWriter, r *http.Request) {
-	userId := chi.URLParam(r, "userId")
-	payload := &user_entity.UpdateNurseUser{
-		UserID: userId,
-	}
+func (c *UserController) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	payload := &auth_entity.LoginMFA{Client: sessionClient(r)}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -388,24 +506,47 @@ func (c *UserController) handleUpdateNurseUser(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	err = c.Service.UpdateNurseUser(r.Context(), payload)
-	if errors.Is(err, user_error.ErrUserNotFound) {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
+	user, err := c.Service.LoginMFA(r.Context(), payload)
+	if errors.Is(err, auth_error.ErrInvalidToken) ||
+		errors.Is(err, auth_error.ErrUnknownClaims) ||
+		errors.Is(err, auth_error.ErrTokenRevoked) ||
+		errors.Is(err, auth_error.ErrAccessRevoked) ||
+		errors.Is(err, user_error.ErrMFANotEnrolled) ||
+		errors.Is(err, user_error.ErrInvalidMFACode) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrUserIsNotNurse) {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
+	if errors.Is(err, user_error.ErrLoginLocked) {
+		helpers.ResponseJSON(w, http.StatusTooManyRequests, &helpers.ResponseBody{
+			Error:   "Too many requests error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
-		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
-			Error:   "Conflict error",
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "User successfully login",
+		Data:    user,
+	})
+}
+
+func (c *UserController) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := c.Service.BeginOIDCLogin(r.Context())
+	if errors.Is(err, auth_error.ErrOIDCDisabled) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
 			Message: err.Error(),
 		})
 		return
@@ -418,25 +559,71 @@ func (c *UserController) handleUpdateNurseUser(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "Nurse user successfully updated",
-	})
+	// The IdP redirects back cross-site, which a strict cookie would not survive.
+	cookie := helpers.NewCookie(helpers.OIDCStateCookie, login.State, helpers.OIDCStateCookiePath, time.Now().Add(auth_entity.OIDCLoginStateTTL), true)
+	if cookie.SameSite == http.SameSiteStrictMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(w, cookie)
+
+	http.Redirect(w, r, login.AuthorizationURL, http.StatusFound)
 }
 
-func (c *UserController) handleDeleteNurseUser(w http.ResponseWriter, r *http.Request) {
-	userId := chi.URLParam(r, "userId")
+func (c *UserController) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
 
-	err := c.Service.DeleteNurseUser(r.Context(), userId)
-	if errors.Is(err, user_error.ErrUserNotFound) {
+	if idpError := query.Get("error"); idpError != "" {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   idpError,
+			Message: auth_error.ErrOIDCLoginFailed.Error(),
+		})
+		return
+	}
+
+	payload := &auth_entity.OIDCCallback{
+		State:  query.Get("state"),
+		Code:   query.Get("code"),
+		Client: sessionClient(r),
+	}
+	if cookie, err := r.Cookie(helpers.OIDCStateCookie); err == nil {
+		payload.CookieState = cookie.Value
+	}
+	http.SetCookie(w, helpers.ExpiredCookie(helpers.OIDCStateCookie, helpers.OIDCStateCookiePath, true))
+
+	err := helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: auth_error.ErrInvalidOIDCState.Error(),
+		})
+		return
+	}
+
+	user, err := c.Service.CompleteOIDCLogin(r.Context(), payload)
+	if errors.Is(err, auth_error.ErrOIDCDisabled) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrUserIsNotNurse) {
-		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
-			Error:   "Not found error",
+	if errors.Is(err, auth_error.ErrInvalidOIDCState) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, auth_error.ErrOIDCLoginFailed) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, auth_error.ErrOIDCUserNotAllowed) {
+		helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+			Error:   "Forbidden error",
 			Message: err.Error(),
 		})
 		return
@@ -449,16 +636,24 @@ func (c *UserController) handleDeleteNurseUser(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if user.MFARequired {
+		helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+			Message: "MFA code required",
+			Data:    user,
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "Nurse user successfully deleted",
+		Message: "User successfully login",
+		Data:    user,
 	})
 }
 
-func (c *UserController) handleGiveAccessNurseUser(w http.ResponseWriter, r *http.Request) {
-	userId := chi.URLParam(r, "userId")
-	payload := &user_entity.GiveAccessNurseUser{
-		UserID: userId,
-	}
+func (c *UserController) handleLoginStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	payload := &user_entity.LoginUser{Role: role, Client: sessionClient(r)}
 
 	err := helpers.DecodeJSON(r, payload)
 	if err != nil {
@@ -478,15 +673,984 @@ func (c *UserController) handleGiveAccessNurseUser(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err = c.Service.GiveAccessNurseUser(r.Context(), payload)
-	if errors.Is(err, user_error.ErrUserNotFound) {
+	user, err := c.Service.LoginUser(r.Context(), payload)
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
 			Message: err.Error(),
 		})
 		return
 	}
-	if errors.Is(err, user_error.ErrUserIsNotNurse) {
+	if errors.Is(err, user_error.ErrInvalidLogin) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrLoginLocked) {
+		helpers.ResponseJSON(w, http.StatusTooManyRequests, &helpers.ResponseBody{
+			Error:   "Too many requests error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "User successfully login",
+		Data:    user,
+	})
+}
+
+func (c *UserController) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	payload := &auth_entity.RefreshTokenPayload{Client: sessionClient(r)}
+
+	// Browser sessions send an empty body and the refresh token cookie.
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	if cookie, err := r.Cookie(helpers.RefreshTokenCookie); err == nil && payload.RefreshToken == "" {
+		if !helpers.VerifyCSRF(r) {
+			helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+				Error:   "Forbidden error",
+				Message: auth_error.ErrInvalidCSRFToken.Error(),
+			})
+			return
+		}
+		payload.RefreshToken = cookie.Value
+	}
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	user, err := c.Service.RefreshToken(r.Context(), payload)
+	if errors.Is(err, auth_error.ErrInvalidRefreshToken) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, auth_error.ErrRefreshTokenReused) {
+		helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+			Error:   "Unauthorized error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "Token successfully refreshed",
+		Data:    user,
+	})
+}
+
+func (c *UserController) handleLogoutUser(w http.ResponseWriter, r *http.Request) {
+	credential, ok := r.Context().Value(middlewares.ContextCredentialKey).(*helpers.Credential)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Credential type assertion failed",
+			Message: "Credential not found in context",
+		})
+		return
+	}
+
+	// The refresh token is optional, an empty body only ends the access token.
+	payload := &auth_entity.LogoutPayload{}
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	if cookie, err := r.Cookie(helpers.RefreshTokenCookie); err == nil && payload.RefreshToken == "" {
+		payload.RefreshToken = cookie.Value
+	}
+
+	err = c.Service.LogoutUser(r.Context(), credential, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	clearSessionCookies(w)
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "User successfully logout",
+	})
+}
+
+func (c *UserController) handleRevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "userId")
+
+	err := c.Service.RevokeUserSessions(r.Context(), userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "User sessions successfully revoked",
+	})
+}
+
+func (c *UserController) handleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "userId")
+
+	err := c.Service.UnlockUser(r.Context(), userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "User successfully unlocked",
+	})
+}
+
+func (c *UserController) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+	payload := &user_entity.ChangePassword{Client: sessionClient(r)}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.UserID = userID
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	user, err := c.Service.ChangePassword(r.Context(), payload)
+	var policyErr *user_error.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+			Data:    policyErr.Violations,
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrInvalidPassword) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Bad request error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	setSessionCookies(w, user)
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "Password successfully changed",
+		Data:    user,
+	})
+}
+
+func (c *UserController) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "userId")
+
+	resetPassword, err := c.Service.ResetPassword(r.Context(), userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserHasNoAccess) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "Password successfully reset",
+		Data:    resetPassword,
+	})
+}
+
+func (c *UserController) handleEnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+
+	enrollment, err := c.Service.EnrollMFA(r.Context(), userID)
+	if errors.Is(err, user_error.ErrUserIsNotIT) {
+		helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+			Error:   "Forbidden error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrMFAAlreadyEnabled) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "MFA enrollment started",
+		Data:    enrollment,
+	})
+}
+
+func (c *UserController) handleActivateMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+	payload := &auth_entity.MFACode{}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.UserID = userID
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	recoveryCodes, err := c.Service.ActivateMFA(r.Context(), payload)
+	if errors.Is(err, user_error.ErrMFAAlreadyEnabled) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrMFANotEnrolled) || errors.Is(err, user_error.ErrInvalidMFACode) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Bad request error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "MFA successfully enabled",
+		Data:    recoveryCodes,
+	})
+}
+
+func (c *UserController) handleDisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+	payload := &auth_entity.MFACode{}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.UserID = userID
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	err = c.Service.DisableMFA(r.Context(), payload)
+	if errors.Is(err, user_error.ErrMFANotEnrolled) || errors.Is(err, user_error.ErrInvalidMFACode) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Bad request error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "MFA successfully disabled",
+	})
+}
+
+func (c *UserController) handleGetUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, offset, err := helpers.ParsePagination(query)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	params := &user_entity.UserQueryParams{
+		UserID:    query.Get("userId"),
+		Limit:     limit,
+		Offset:    offset,
+		NIP:       query.Get("nip"),
+		Name:      query.Get("name"),
+		Role:      query.Get("role"),
+		CreatedAt: query.Get("createdAt"),
+	}
+
+	if deletedStr := query.Get("deleted"); deletedStr != "" {
+		params.Deleted, err = strconv.ParseBool(deletedStr)
+		if err != nil {
+			helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+				Error:   "deleted must be true or false",
+				Message: "Request doesn’t pass validation",
+			})
+			return
+		}
+	}
+
+	err = parseNIPFilters(query, params)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	users, total, err := c.Service.GetUsers(r.Context(), params)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message:    "success",
+		Data:       users,
+		Pagination: helpers.NewPagination(r, total, limit, offset),
+	})
+}
+
+// parseNIPFilters reads the nipYear, nipMonth and gender query parameters,
+// which filter on the components of the NIP.
+func parseNIPFilters(query url.Values, params *user_entity.UserQueryParams) error {
+	if value := query.Get("nipYear"); value != "" {
+		year, err := strconv.Atoi(value)
+		if err != nil || year < nip.MinYear || year > time.Now().Year() {
+			return fmt.Errorf("nipYear must be a year between %d and %d", nip.MinYear, time.Now().Year())
+		}
+		params.NIPYear = year
+	}
+
+	if value := query.Get("nipMonth"); value != "" {
+		month, err := strconv.Atoi(value)
+		if err != nil || month < 1 || month > 12 {
+			return fmt.Errorf("nipMonth must be an integer between 1 and 12")
+		}
+		params.NIPMonth = time.Month(month)
+	}
+
+	if value := query.Get("gender"); value != "" {
+		gender, err := nip.ParseGender(value)
+		if err != nil {
+			return fmt.Errorf("gender must be male or female")
+		}
+		params.Gender = gender
+	}
+
+	return nil
+}
+
+func (c *UserController) handleUpdateStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	userId := chi.URLParam(r, "userId")
+	payload := &user_entity.UpdateStaffUser{
+		UserID: userId,
+	}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	if payload.NIP.Prefix() != role.NIPPrefix() {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	err = c.Service.UpdateStaffUser(r.Context(), role, payload)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: roleTitle(role) + " user successfully updated",
+	})
+}
+
+func (c *UserController) handleDeleteStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	userId := chi.URLParam(r, "userId")
+
+	err := c.Service.DeleteStaffUser(r.Context(), role, userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: roleTitle(role) + " user successfully deleted",
+	})
+}
+
+func (c *UserController) handleRestoreStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	userId := chi.URLParam(r, "userId")
+
+	err := c.Service.RestoreStaffUser(r.Context(), role, userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserNotDeleted) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrNIPAlreadyExists) {
+		helpers.ResponseJSON(w, http.StatusConflict, &helpers.ResponseBody{
+			Error:   "Conflict error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: roleTitle(role) + " user successfully restored",
+	})
+}
+
+func (c *UserController) handleGiveAccessStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	userId := chi.URLParam(r, "userId")
+	payload := &user_entity.GiveAccessStaffUser{
+		UserID: userId,
+	}
+
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	err = c.Service.GiveAccessStaffUser(r.Context(), role, payload)
+	var policyErr *user_error.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+			Data:    policyErr.Violations,
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrInvalidAccessExpiry) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: roleTitle(role) + " user successfully granted access",
+	})
+}
+
+func (c *UserController) handleRevokeAccessStaffUser(w http.ResponseWriter, r *http.Request, role user_entity.Role) {
+	userId := chi.URLParam(r, "userId")
+
+	err := c.Service.RevokeAccessStaffUser(r.Context(), role, userId)
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserIsNotNurse) || errors.Is(err, user_error.ErrUserRoleMismatch) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: roleTitle(role) + " user access successfully revoked",
+	})
+}
+
+func (c *UserController) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+
+	payload := &auth_entity.AddAPIKey{}
+	err := helpers.DecodeJSON(r, payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   err.Error(),
+			Message: "Failed to decode JSON",
+		})
+		return
+	}
+	payload.CreatedBy = userId
+
+	err = helpers.ValidatePayload(payload)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: "Request doesn’t pass validation",
+		})
+		return
+	}
+
+	apiKey, err := c.Service.CreateAPIKey(r.Context(), payload)
+	if errors.Is(err, auth_error.ErrInvalidScope) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, auth_error.ErrInvalidAPIKeyExpiry) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, &helpers.ResponseBody{
+			Error:   "Validation error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusCreated, &helpers.ResponseBody{
+		Message: "API key successfully created, store the key now as it will not be shown again",
+		Data:    apiKey,
+	})
+}
+
+func (c *UserController) handleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	apiKeys, err := c.Service.GetAPIKeys(r.Context())
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "success",
+		Data:    apiKeys,
+	})
+}
+
+func (c *UserController) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	apiKeyId := chi.URLParam(r, "apiKeyId")
+
+	err := c.Service.RevokeAPIKey(r.Context(), apiKeyId)
+	if errors.Is(err, auth_error.ErrAPIKeyNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "API key successfully revoked",
+	})
+}
+
+// setSessionCookies stores the tokens for browser clients. The CSRF token is
+// readable by the front-end so it can echo it in the X-CSRF-Token header.
+func setSessionCookies(w http.ResponseWriter, user *user_entity.LoggedInUser) {
+	now := time.Now()
+	http.SetCookie(w, helpers.NewCookie(helpers.AccessTokenCookie, user.AccessToken, "/", now.Add(auth_entity.AccessTokenTTL), true))
+
+	if user.RefreshToken != "" {
+		http.SetCookie(w, helpers.NewCookie(helpers.RefreshTokenCookie, user.RefreshToken, helpers.RefreshTokenCookiePath, now.Add(auth_entity.RefreshTokenTTL), true))
+	}
+
+	csrfToken, err := helpers.GenerateRandomToken(32)
+	if err != nil {
+		log.Printf("failed to generate CSRF token: %v", err)
+		return
+	}
+	http.SetCookie(w, helpers.NewCookie(helpers.CSRFTokenCookie, csrfToken, "/", now.Add(auth_entity.RefreshTokenTTL), false))
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, helpers.ExpiredCookie(helpers.AccessTokenCookie, "/", true))
+	http.SetCookie(w, helpers.ExpiredCookie(helpers.RefreshTokenCookie, helpers.RefreshTokenCookiePath, true))
+	http.SetCookie(w, helpers.ExpiredCookie(helpers.CSRFTokenCookie, "/", false))
+}
+
+func (c *UserController) handleGetMySessions(w http.ResponseWriter, r *http.Request) {
+	credential, ok := r.Context().Value(middlewares.ContextCredentialKey).(*helpers.Credential)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Credential type assertion failed",
+			Message: "Credential not found in context",
+		})
+		return
+	}
+
+	sessions, err := c.Service.GetSessions(r.Context(), credential.UserId, credential.SessionID)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "success",
+		Data:    sessions,
+	})
+}
+
+func (c *UserController) handleTerminateMySession(w http.ResponseWriter, r *http.Request) {
+	userId, ok := r.Context().Value(middlewares.ContextUserIDKey).(string)
+	if !ok {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "User ID type assertion failed",
+			Message: "User ID not found in context",
+		})
+		return
+	}
+	c.terminateSession(w, r, userId)
+}
+
+func (c *UserController) handleGetUserSessions(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "userId")
+
+	sessions, err := c.Service.GetSessions(r.Context(), userId, "")
+	if errors.Is(err, user_error.ErrUserNotFound) {
+		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+			Error:   "Not found error",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
+		Message: "success",
+		Data:    sessions,
+	})
+}
+
+func (c *UserController) handleTerminateUserSession(w http.ResponseWriter, r *http.Request) {
+	c.terminateSession(w, r, chi.URLParam(r, "userId"))
+}
+
+func (c *UserController) terminateSession(w http.ResponseWriter, r *http.Request, userId string) {
+	sessionId := chi.URLParam(r, "sessionId")
+
+	err := c.Service.TerminateSession(r.Context(), userId, sessionId)
+	if errors.Is(err, auth_error.ErrSessionNotFound) {
 		helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
 			Error:   "Not found error",
 			Message: err.Error(),
@@ -502,6 +1666,45 @@ func (c *UserController) handleGiveAccessNurseUser(w http.ResponseWriter, r *htt
 	}
 
 	helpers.ResponseJSON(w, http.StatusOK, &helpers.ResponseBody{
-		Message: "Nurse user successfully granted access",
+		Message: "Session successfully terminated",
 	})
 }
+
+func sessionClient(r *http.Request) auth_entity.SessionClient {
+	return auth_entity.SessionClient{
+		UserAgent: r.UserAgent(),
+		IPAddress: helpers.ClientIP(r),
+	}
+}
+
+type staffHandler func(w http.ResponseWriter, r *http.Request, role user_entity.Role)
+
+// forRole binds a staff handler to one role, as the nurse routes do.
+func forRole(role user_entity.Role, handler staffHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, role)
+	}
+}
+
+// forStaffRole takes the role from the {role} path parameter.
+func forStaffRole(handler staffHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := user_entity.Role(chi.URLParam(r, "role"))
+		if !role.IsStaff() {
+			helpers.ResponseJSON(w, http.StatusNotFound, &helpers.ResponseBody{
+				Error:   "Not found error",
+				Message: user_error.ErrUnknownRole.Error(),
+			})
+			return
+		}
+		handler(w, r, role)
+	}
+}
+
+func roleTitle(role user_entity.Role) string {
+	name := string(role)
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/domains/values/nip"
+)
+
+// fakeUserService records the query parameters GetUsers was called with.
+type fakeUserService struct {
+	interfaces.UserService
+	params *user_entity.UserQueryParams
+}
+
+func (s *fakeUserService) GetUsers(ctx context.Context, params *user_entity.UserQueryParams) ([]*user_entity.UserList, int, error) {
+	s.params = params
+	return []*user_entity.UserList{}, 0, nil
+}
+
+func TestHandleGetUsersFilters(t *testing.T) {
+	nextYear := strconv.Itoa(time.Now().Year() + 1)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"nipYear=2024&nipMonth=3&gender=female", http.StatusOK},
+		{"nipYear=2000", http.StatusOK},
+		{"nipYear=1999", http.StatusBadRequest},
+		{"nipYear=" + nextYear, http.StatusBadRequest},
+		{"nipYear=twenty", http.StatusBadRequest},
+		{"nipMonth=0", http.StatusBadRequest},
+		{"nipMonth=13", http.StatusBadRequest},
+		{"nipMonth=march", http.StatusBadRequest},
+		{"gender=other", http.StatusBadRequest},
+		{"limit=0", http.StatusBadRequest},
+		{"deleted=true", http.StatusOK},
+		{"deleted=false", http.StatusOK},
+		{"deleted=yes", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			service := &fakeUserService{}
+			controller := &UserController{Service: service}
+
+			w := httptest.NewRecorder()
+			controller.handleGetUsers(w, httptest.NewRequest(http.MethodGet, "/v1/user?"+tt.query, nil))
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK && service.params != nil {
+				t.Fatal("service called for an invalid query")
+			}
+		})
+	}
+}
+
+func TestParseNIPFilters(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/user?nipYear=2024&nipMonth=3&gender=female", nil)
+	params := &user_entity.UserQueryParams{}
+
+	if err := parseNIPFilters(r.URL.Query(), params); err != nil {
+		t.Fatal(err)
+	}
+	if params.NIPYear != 2024 || params.NIPMonth != time.March || params.Gender != nip.Female {
+		t.Fatalf("unexpected params %+v", params)
+	}
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/danzBraham/halo-suster/internal/helpers"
+	"github.com/go-chi/chi/v5"
+)
+
+type WellKnownController struct{}
+
+func NewWellKnownController() *WellKnownController {
+	return &WellKnownController{}
+}
+
+func (c *WellKnownController) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/jwks.json", c.handleGetJWKS)
+
+	return r
+}
+
+func (c *WellKnownController) handleGetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	helpers.ResponseJSON(w, http.StatusOK, helpers.JWKS())
+}
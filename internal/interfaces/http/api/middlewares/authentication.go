@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
 	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
 	"github.com/danzBraham/halo-suster/internal/helpers"
 )
@@ -13,56 +14,144 @@ import (
 type ContextKey string
 
 var (
-	ContextUserIDKey ContextKey = "userID"
-	ContextRoleKey   ContextKey = "role"
+	ContextUserIDKey     ContextKey = "userID"
+	ContextRoleKey       ContextKey = "role"
+	ContextCredentialKey ContextKey = "credential"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
+type AuthMiddleware struct {
+	UserService interfaces.UserService
+}
+
+func NewAuthMiddleware(userService interfaces.UserService) *AuthMiddleware {
+	return &AuthMiddleware{UserService: userService}
+}
+
+// Authenticate accepts either a bearer access token or an X-API-Key.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return m.authenticate(next, false)
+}
+
+// AllowPendingPasswordChange authenticates like Authenticate but also lets
+// through users that still have to replace a temporary password. API keys are
+// not accepted since these routes act on the user's own session.
+func (m *AuthMiddleware) AllowPendingPasswordChange(next http.Handler) http.Handler {
+	return m.authenticate(next, true)
+}
+
+func (m *AuthMiddleware) authenticate(next http.Handler, allowPendingPasswordChange bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		var credential *helpers.Credential
+		var err error
+
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" && !allowPendingPasswordChange {
+			credential, err = m.UserService.AuthenticateAPIKey(r.Context(), apiKey)
+		} else {
+			tokenString, fromCookie, ok := accessToken(r)
+			if !ok {
+				helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+					Error:   "Unauthorized error",
+					Message: "Missing Authorization header",
+				})
+				return
+			}
+
+			if tokenString == "" {
+				helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+					Error:   "Unauthorized error",
+					Message: "Invalid Authorization header format",
+				})
+				return
+			}
+
+			// Browsers attach the cookie to cross-site requests too, so
+			// state-changing requests must also prove they can read it.
+			if fromCookie && !helpers.IsSafeMethod(r.Method) && !helpers.VerifyCSRF(r) {
+				helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+					Error:   "Forbidden error",
+					Message: auth_error.ErrInvalidCSRFToken.Error(),
+				})
+				return
+			}
+
+			credential, err = m.UserService.Authenticate(r.Context(), tokenString)
+		}
+		if errors.Is(err, auth_error.ErrInvalidAPIKey) {
 			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
 				Error:   "Unauthorized error",
-				Message: "Missing Authorization header",
+				Message: err.Error(),
 			})
 			return
 		}
-
-		tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer"))
-		if tokenString == "" {
+		if errors.Is(err, auth_error.ErrInvalidToken) {
 			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
 				Error:   "Unauthorized error",
-				Message: "Invalid Authorization header format",
+				Message: err.Error(),
 			})
 			return
 		}
-
-		credential, err := helpers.VerifyJWT(tokenString)
-		if errors.Is(err, auth_error.ErrInvalidToken) {
+		if errors.Is(err, auth_error.ErrUnknownClaims) {
 			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
 				Error:   "Unauthorized error",
 				Message: err.Error(),
 			})
 			return
 		}
-		if errors.Is(err, auth_error.ErrUnknownClaims) {
+		if errors.Is(err, auth_error.ErrTokenRevoked) {
 			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
 				Error:   "Unauthorized error",
 				Message: err.Error(),
 			})
 			return
 		}
-		if err != nil {
+		if errors.Is(err, auth_error.ErrSessionTerminated) {
+			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+				Error:   "Unauthorized error",
+				Message: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, auth_error.ErrAccessRevoked) {
 			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
 				Error:   "Unauthorized error",
 				Message: err.Error(),
 			})
 			return
 		}
+		if err != nil {
+			helpers.ResponseJSON(w, http.StatusInternalServerError, &helpers.ResponseBody{
+				Error:   "Internal server error",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if credential.MustChangePassword && !allowPendingPasswordChange {
+			helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+				Error:   "Forbidden error",
+				Message: auth_error.ErrPasswordChangeRequired.Error(),
+			})
+			return
+		}
 
 		ctx := context.WithValue(r.Context(), ContextUserIDKey, credential.UserId)
 		ctx = context.WithValue(ctx, ContextRoleKey, credential.Role)
+		ctx = context.WithValue(ctx, ContextCredentialKey, credential)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// accessToken reads the bearer token from the Authorization header, falling
+// back to the session cookie set at login.
+func accessToken(r *http.Request) (token string, fromCookie bool, ok bool) {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer")), false, true
+	}
+
+	cookie, err := r.Cookie(helpers.AccessTokenCookie)
+	if err != nil {
+		return "", false, false
+	}
+	return cookie.Value, true, true
+}
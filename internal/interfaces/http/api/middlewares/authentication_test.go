@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danzBraham/halo-suster/internal/applications/interfaces"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+// fakeUserService answers Authenticate with a fixed credential or error.
+type fakeUserService struct {
+	interfaces.UserService
+	credential *helpers.Credential
+	err        error
+}
+
+func (s *fakeUserService) Authenticate(ctx context.Context, accessToken string) (*helpers.Credential, error) {
+	return s.credential, s.err
+}
+
+func (s *fakeUserService) AuthenticateAPIKey(ctx context.Context, key string) (*helpers.Credential, error) {
+	return s.credential, s.err
+}
+
+func serveAuthenticated(t *testing.T, service *fakeUserService, r *http.Request) (int, *helpers.Credential) {
+	t.Helper()
+	var seen *helpers.Credential
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = r.Context().Value(ContextCredentialKey).(*helpers.Credential)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	NewAuthMiddleware(service).Authenticate(next).ServeHTTP(w, r)
+	return w.Code, seen
+}
+
+func TestAuthenticateRejectsRevokedUsers(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"access revoked", auth_error.ErrAccessRevoked},
+		{"token revoked", auth_error.ErrTokenRevoked},
+		{"session terminated", auth_error.ErrSessionTerminated},
+		{"invalid token", auth_error.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer token")
+
+			code, _ := serveAuthenticated(t, &fakeUserService{err: tt.err}, r)
+			if code != http.StatusUnauthorized {
+				t.Fatalf("got status %d, want %d", code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestAuthenticateMissingHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	code, _ := serveAuthenticated(t, &fakeUserService{}, r)
+	if code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthenticatePassesCredential(t *testing.T) {
+	credential := &helpers.Credential{UserId: "user-1", Role: user_entity.Nurse}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+
+	code, seen := serveAuthenticated(t, &fakeUserService{credential: credential}, r)
+	if code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", code, http.StatusNoContent)
+	}
+	if seen != credential {
+		t.Fatal("credential not passed to the handler")
+	}
+}
+
+func TestAuthenticatePendingPasswordChange(t *testing.T) {
+	credential := &helpers.Credential{UserId: "user-1", Role: user_entity.IT, MustChangePassword: true}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+
+	code, _ := serveAuthenticated(t, &fakeUserService{credential: credential}, r)
+	if code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestAuthenticateCookieRequiresCSRFToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		csrf   string
+		want   int
+	}{
+		{"safe method", http.MethodGet, "", http.StatusNoContent},
+		{"missing token", http.MethodPost, "", http.StatusForbidden},
+		{"wrong token", http.MethodDelete, "other", http.StatusForbidden},
+		{"matching token", http.MethodPost, "csrf", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.AddCookie(&http.Cookie{Name: helpers.AccessTokenCookie, Value: "token"})
+			r.AddCookie(&http.Cookie{Name: helpers.CSRFTokenCookie, Value: "csrf"})
+			if tt.csrf != "" {
+				r.Header.Set(helpers.CSRFTokenHeader, tt.csrf)
+			}
+
+			service := &fakeUserService{credential: &helpers.Credential{UserId: "user-1", Role: user_entity.IT}}
+			code, _ := serveAuthenticated(t, service, r)
+			if code != tt.want {
+				t.Fatalf("got status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateBearerSkipsCSRFCheck(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+
+	service := &fakeUserService{credential: &helpers.Credential{UserId: "user-1", Role: user_entity.IT}}
+	if code, _ := serveAuthenticated(t, service, r); code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", code, http.StatusNoContent)
+	}
+}
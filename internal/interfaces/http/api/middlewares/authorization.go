@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	auth_error "github.com/danzBraham/halo-suster/internal/exceptions/auth"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+func RequirePermission(permissions ...permission_entity.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := r.Context().Value(ContextRoleKey).(user_entity.Role)
+			if !ok {
+				helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+					Error:   "Unauthorized error",
+					Message: "Role not found in context",
+				})
+				return
+			}
+
+			for _, permission := range permissions {
+				if !permission_entity.HasPermission(role, permission) || !hasScope(r, permission) {
+					helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+						Error:   "Forbidden error",
+						Message: auth_error.ErrPermissionDenied.Error(),
+					})
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Can reports whether the request may use permission, for handlers where a
+// permission only gates part of the payload.
+func Can(r *http.Request, permission permission_entity.Permission) bool {
+	role, _ := r.Context().Value(ContextRoleKey).(user_entity.Role)
+	return permission_entity.HasPermission(role, permission) && hasScope(r, permission)
+}
+
+// API keys are limited to their scopes on top of the owner's role.
+func hasScope(r *http.Request, permission permission_entity.Permission) bool {
+	credential, _ := r.Context().Value(ContextCredentialKey).(*helpers.Credential)
+	if credential == nil || credential.APIKeyID == "" {
+		return true
+	}
+	for _, scope := range credential.Scopes {
+		if scope == string(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireUserSession rejects API keys on routes that only make sense for a
+// person, such as MFA enrollment or API key management.
+func RequireUserSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential, ok := r.Context().Value(ContextCredentialKey).(*helpers.Credential)
+		if !ok {
+			helpers.ResponseJSON(w, http.StatusUnauthorized, &helpers.ResponseBody{
+				Error:   "Unauthorized error",
+				Message: "Credential not found in context",
+			})
+			return
+		}
+
+		if credential.APIKeyID != "" {
+			helpers.ResponseJSON(w, http.StatusForbidden, &helpers.ResponseBody{
+				Error:   "Forbidden error",
+				Message: auth_error.ErrUserSessionRequired.Error(),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	permission_entity "github.com/danzBraham/halo-suster/internal/domains/entities/permissions"
+	user_entity "github.com/danzBraham/halo-suster/internal/domains/entities/users"
+	"github.com/danzBraham/halo-suster/internal/helpers"
+)
+
+func withCredential(r *http.Request, credential *helpers.Credential) *http.Request {
+	ctx := context.WithValue(r.Context(), ContextRoleKey, credential.Role)
+	ctx = context.WithValue(ctx, ContextCredentialKey, credential)
+	return r.WithContext(ctx)
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		credential *helpers.Credential
+		permission permission_entity.Permission
+		want       int
+	}{
+		{"no credential", nil, permission_entity.PatientsRead, http.StatusUnauthorized},
+		{"granted", &helpers.Credential{Role: user_entity.Nurse}, permission_entity.PatientsRead, http.StatusNoContent},
+		{"denied", &helpers.Credential{Role: user_entity.Nurse}, permission_entity.UsersWrite, http.StatusForbidden},
+		{"pharmacist cannot write records", &helpers.Credential{Role: user_entity.Pharmacist}, permission_entity.RecordsWrite, http.StatusForbidden},
+		{"api key in scope", &helpers.Credential{Role: user_entity.IT, APIKeyID: "key-1", Scopes: []string{"patients:read"}}, permission_entity.PatientsRead, http.StatusNoContent},
+		{"api key out of scope", &helpers.Credential{Role: user_entity.IT, APIKeyID: "key-1", Scopes: []string{"patients:read"}}, permission_entity.UsersRead, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.credential != nil {
+				r = withCredential(r, tt.credential)
+			}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			RequirePermission(tt.permission)(next).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermissionNeedsAll(t *testing.T) {
+	r := withCredential(httptest.NewRequest(http.MethodGet, "/", nil), &helpers.Credential{Role: user_entity.Registrar})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	RequirePermission(permission_entity.PatientsRead, permission_entity.RecordsRead)(next).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireUserSessionRejectsAPIKeys(t *testing.T) {
+	r := withCredential(httptest.NewRequest(http.MethodGet, "/", nil), &helpers.Credential{Role: user_entity.IT, APIKeyID: "key-1"})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	RequireUserSession(next).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}